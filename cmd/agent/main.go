@@ -2,11 +2,15 @@ package main
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"monserv/internal/agent"
+	"monserv/internal/metrics"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -17,26 +21,131 @@ func main() {
 	r := gin.Default()
 
 	coll := agent.NewCollector()
+	// Set to "off" to disable container metrics or Docker enrichment
+	if v, ok := os.LookupEnv("AGENT_CGROUP_ROOT"); ok {
+		coll.CgroupRoot = disabledIfOff(v)
+	}
+	if v, ok := os.LookupEnv("AGENT_DOCKER_SOCKET"); ok {
+		coll.DockerSocket = disabledIfOff(v)
+	}
+	// Watched systemd units, comma separated
+	for _, u := range strings.Split(os.Getenv("AGENT_SYSTEMD_UNITS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			coll.Units = append(coll.Units, u)
+		}
+	}
+	// Plugin checks (Nagios compatible) from a JSON file
+	if path := os.Getenv("AGENT_CHECKS_FILE"); path != "" {
+		checks, err := agent.LoadChecks(path)
+		if err != nil {
+			log.Fatalf("agent checks: %v", err)
+		}
+		coll.Checks = agent.NewCheckRunner(checks)
+		go coll.Checks.Run(make(chan struct{}))
+	}
+	// Process watch list from a JSON file
+	if path := os.Getenv("AGENT_PROCESS_WATCH_FILE"); path != "" {
+		watches, err := metrics.LoadProcessMatchers(path)
+		if err != nil {
+			log.Fatalf("agent process watches: %v", err)
+		}
+		coll.ProcessWatches = watches
+	}
+	// Log watches (regex match counters over tailed files) from a JSON file
+	if path := os.Getenv("AGENT_LOG_WATCH_FILE"); path != "" {
+		watches, err := agent.LoadLogWatches(path)
+		if err != nil {
+			log.Fatalf("agent log watches: %v", err)
+		}
+		if coll.LogWatches, err = agent.NewLogWatcher(watches); err != nil {
+			log.Fatalf("agent log watches: %v", err)
+		}
+		go coll.LogWatches.Run(make(chan struct{}))
+	}
+
+	// Requests are served from a background snapshot; AGENT_SAMPLE_INTERVAL_SECONDS=0 collects per request instead
+	var src agent.Source = coll
+	sampleEvery := 5
+	if n, err := strconv.Atoi(os.Getenv("AGENT_SAMPLE_INTERVAL_SECONDS")); err == nil && n >= 0 {
+		sampleEvery = n
+	}
+	if sampleEvery > 0 {
+		sampler := agent.NewSampler(coll, time.Duration(sampleEvery)*time.Second)
+		go sampler.Run(make(chan struct{}))
+		src = sampler
+	}
+
+	// Push mode: send metrics to the server instead of (or in addition to) being polled
+	if url := os.Getenv("AGENT_PUSH_URL"); url != "" {
+		pusher := agent.NewPusher(src, url, os.Getenv("AGENT_PUSH_TOKEN"))
+		pusher.AgentID = os.Getenv("AGENT_ID")
+		if n, err := strconv.Atoi(os.Getenv("AGENT_PUSH_INTERVAL_SECONDS")); err == nil && n > 0 {
+			pusher.Interval = time.Duration(n) * time.Second
+		}
+		go pusher.Run(make(chan struct{}))
+	}
 
 	r.GET("/health", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
-	r.GET("/metrics", func(c *gin.Context) {
+	auth := agent.RequireToken(os.Getenv("AGENT_TOKEN"))
+	collect := func(c *gin.Context) (*metrics.ServerMetrics, bool) {
 		ctx := c.Request.Context()
 		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < time.Second {
 			var cancel context.CancelFunc
 			ctx, cancel = context.WithTimeout(ctx, 5*time.Second)
 			defer cancel()
 		}
-		m, err := coll.Collect(ctx)
+		m, err := src.Collect(ctx)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		return m, true
+	}
+	writePrometheus := func(c *gin.Context, m *metrics.ServerMetrics) {
+		c.Header("Content-Type", metrics.PrometheusContentType)
+		c.Status(http.StatusOK)
+		_ = m.WritePrometheus(c.Writer)
+	}
+	r.GET("/metrics", auth, func(c *gin.Context) {
+		m, ok := collect(c)
+		if !ok {
+			return
+		}
+		// Prometheus scrapers ask for the text format; everything else gets JSON
+		if accept := c.GetHeader("Accept"); strings.Contains(accept, "text/plain") || strings.Contains(accept, "application/openmetrics-text") {
+			writePrometheus(c, m)
 			return
 		}
 		c.JSON(http.StatusOK, m)
 	})
+	r.GET("/metrics/prometheus", auth, func(c *gin.Context) {
+		if m, ok := collect(c); ok {
+			writePrometheus(c, m)
+		}
+	})
 
 	port := os.Getenv("AGENT_PORT")
 	if port == "" {
 		port = "9123"
 	}
-	_ = r.Run(":" + port)
+
+	// TLS is enabled when a certificate is configured; AGENT_TLS_CLIENT_CA additionally requires client certificates
+	certFile, keyFile := os.Getenv("AGENT_TLS_CERT"), os.Getenv("AGENT_TLS_KEY")
+	if certFile == "" {
+		_ = r.Run(":" + port)
+		return
+	}
+	tlsCfg, err := agent.ServerTLSConfig(os.Getenv("AGENT_TLS_CLIENT_CA"))
+	if err != nil {
+		log.Fatalf("agent TLS: %v", err)
+	}
+	srv := &http.Server{Addr: ":" + port, Handler: r, TLSConfig: tlsCfg}
+	log.Fatal(srv.ListenAndServeTLS(certFile, keyFile))
+}
+
+func disabledIfOff(v string) string {
+	if v == "off" {
+		return ""
+	}
+	return v
 }
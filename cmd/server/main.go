@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "monserv/docs"
@@ -37,6 +41,10 @@ import (
 // @contact.name API Support
 // @contact.email support@monserv.local
 // @BasePath /api
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Bearer token for agent ingestion, e.g. "Bearer <INGEST_TOKEN>"
 func main() {
 	_ = godotenv.Load() // load .env if present
 	cfg := srv.LoadConfig()
@@ -72,8 +80,11 @@ func main() {
 	p.Repo = repo
 	p.WSHub = hub
 	stop := make(chan struct{})
-	go p.Start(stop)
-	defer close(stop)
+	pollerDone := make(chan struct{})
+	go func() {
+		p.Start(stop)
+		close(pollerDone)
+	}()
 
 	r := gin.Default()
 	swaggerURL := ginSwagger.URL("/swagger/doc.json")
@@ -86,6 +97,11 @@ func main() {
 	apiGroup := r.Group("/api")
 	metricsController := controller.NewMetricsController(metricsService)
 	metricsController.RegisterRoutes(apiGroup)
+	ingestController := controller.NewIngestController(p, cfg.IngestToken)
+	ingestController.RegisterRoutes(apiGroup)
+	if cfg.IngestToken == "" {
+		log.Printf("Push ingestion disabled (INGEST_TOKEN not set)")
+	}
 
 	tmpl := template.Must(template.ParseFiles("web/templates/index.html"))
 	r.GET("/", func(c *gin.Context) {
@@ -151,5 +167,23 @@ func main() {
 	log.Printf("Server starting on :%s", port)
 	log.Printf("Swagger UI available at http://%s/swagger/index.html", api_host)
 	log.Printf("WebSocket endpoint at ws://%s/ws", api_host)
-	_ = r.Run(":" + port)
+
+	// Stop on SIGINT/SIGTERM: finish HTTP requests, then let the poller close its SSH connections
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	httpServer := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("HTTP server: %v", err)
+		}
+	}()
+	<-ctx.Done()
+	log.Printf("Shutting down")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown: %v", err)
+	}
+	close(stop)
+	<-pollerDone
 }
@@ -0,0 +1,351 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	m "monserv/internal/metrics"
+)
+
+// maxCheckOutput caps the plugin output kept per check
+const maxCheckOutput = 4096
+
+// CheckConfig describes one plugin check, as read from the checks file:
+//
+//	[{"name": "modbus", "command": ["/usr/lib/nagios/plugins/check_tcp", "-H", "127.0.0.1", "-p", "502"],
+//	  "intervalSeconds": 60, "timeoutSeconds": 10, "format": "nagios"}]
+//
+// Format "nagios" (default) uses the exit code and parses perfdata after "|".
+// Format "json" expects {"status": "WARNING" or 1, "output": "...", "perfdata": [...]} on stdout.
+type CheckConfig struct {
+	Name            string   `json:"name"`
+	Command         []string `json:"command"`
+	IntervalSeconds int      `json:"intervalSeconds"`
+	TimeoutSeconds  int      `json:"timeoutSeconds"`
+	Format          string   `json:"format"`
+}
+
+// LoadChecks reads check definitions from a JSON file and applies defaults
+func LoadChecks(path string) ([]CheckConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var checks []CheckConfig
+	if err := json.Unmarshal(b, &checks); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i := range checks {
+		ck := &checks[i]
+		if ck.Name == "" || len(ck.Command) == 0 {
+			return nil, fmt.Errorf("%s: check %d needs a name and a command", path, i+1)
+		}
+		if seen[ck.Name] {
+			return nil, fmt.Errorf("%s: duplicate check name %q", path, ck.Name)
+		}
+		seen[ck.Name] = true
+		if ck.IntervalSeconds <= 0 {
+			ck.IntervalSeconds = 60
+		}
+		if ck.TimeoutSeconds <= 0 {
+			ck.TimeoutSeconds = 10
+		}
+		if ck.Format == "" {
+			ck.Format = "nagios"
+		}
+	}
+	return checks, nil
+}
+
+// CheckRunner runs plugin checks on their own schedules and keeps the latest result of each
+type CheckRunner struct {
+	Checks []CheckConfig
+
+	mu      sync.RWMutex
+	results map[string]m.CheckResult
+}
+
+func NewCheckRunner(checks []CheckConfig) *CheckRunner {
+	return &CheckRunner{Checks: checks, results: map[string]m.CheckResult{}}
+}
+
+// Run starts one scheduling loop per check until stop is closed
+func (r *CheckRunner) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, ck := range r.Checks {
+		wg.Add(1)
+		go func(ck CheckConfig) {
+			defer wg.Done()
+			ticker := time.NewTicker(time.Duration(ck.IntervalSeconds) * time.Second)
+			defer ticker.Stop()
+			for {
+				res := runCheck(ck)
+				if res.Status != m.CheckOK {
+					log.Printf("[CHECK] %s %s: %s", ck.Name, res.Status, firstLine(res.Output))
+				}
+				r.mu.Lock()
+				r.results[ck.Name] = res
+				r.mu.Unlock()
+				select {
+				case <-ticker.C:
+				case <-stop:
+					return
+				}
+			}
+		}(ck)
+	}
+	wg.Wait()
+}
+
+// Results returns the latest result of every check that has run, sorted by name
+func (r *CheckRunner) Results() []m.CheckResult {
+	out := []m.CheckResult{}
+	if r == nil {
+		return out
+	}
+	r.mu.RLock()
+	for _, res := range r.results {
+		out = append(out, res)
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func runCheck(ck CheckConfig) m.CheckResult {
+	timeout := time.Duration(ck.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ck.Command[0], ck.Command[1:]...)
+	// plugins that fork children must not keep the pipes open after the timeout
+	cmd.WaitDelay = time.Second
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stdout
+	started := time.Now()
+	err := cmd.Run()
+	res := m.CheckResult{
+		Name:         ck.Name,
+		Perfdata:     []m.PerfData{},
+		DurationMs:   float64(time.Since(started).Microseconds()) / 1000,
+		CheckedAtUTC: time.Now().UTC(),
+	}
+
+	var ee *exec.ExitError
+	switch {
+	// a plugin that exited on its own is not a timeout, even if a child held the output open
+	case ctx.Err() == context.DeadlineExceeded && (cmd.ProcessState == nil || !cmd.ProcessState.Exited()):
+		res.ExitCode = 3
+		res.Status = m.CheckUnknown
+		res.Output = fmt.Sprintf("check timed out after %s", timeout)
+		return res
+	case errors.As(err, &ee):
+		res.ExitCode = ee.ExitCode()
+	case errors.Is(err, exec.ErrWaitDelay):
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	case err != nil:
+		// could not start: missing executable, permissions, ...
+		res.ExitCode = 3
+		res.Status = m.CheckUnknown
+		res.Output = err.Error()
+		return res
+	}
+
+	out := stdout.String()
+	if ck.Format == "json" {
+		parseJSONCheckOutput(out, &res)
+	} else {
+		res.Status = nagiosStatus(res.ExitCode)
+		res.Output, res.Perfdata = parseNagiosOutput(out)
+	}
+	res.Output = truncateOutput(res.Output, maxCheckOutput)
+	return res
+}
+
+// truncateOutput cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncateOutput(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func nagiosStatus(code int) string {
+	switch code {
+	case 0:
+		return m.CheckOK
+	case 1:
+		return m.CheckWarning
+	case 2:
+		return m.CheckCritical
+	default:
+		return m.CheckUnknown
+	}
+}
+
+// parseNagiosOutput splits plugin output into text and perfdata. Perfdata follows the
+// first "|" of the first line and, for long output, the first "|" of the following lines.
+func parseNagiosOutput(out string) (string, []m.PerfData) {
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	var text, perf []string
+	t, p, _ := strings.Cut(lines[0], "|")
+	text = append(text, strings.TrimSpace(t))
+	perf = append(perf, p)
+	inPerf := false
+	for _, line := range lines[1:] {
+		if inPerf {
+			perf = append(perf, line)
+			continue
+		}
+		t, p, found := strings.Cut(line, "|")
+		text = append(text, t)
+		if found {
+			perf = append(perf, p)
+			inPerf = true
+		}
+	}
+	return strings.TrimSpace(strings.Join(text, "\n")), parsePerfdata(strings.Join(perf, " "))
+}
+
+// parsePerfdata parses space separated 'label'=value[UOM];[warn];[crit];[min];[max] items.
+// Labels may be single-quoted to contain spaces; a doubled quote inside is a literal quote.
+func parsePerfdata(s string) []m.PerfData {
+	out := []m.PerfData{}
+	for _, item := range splitPerfItems(s) {
+		label, rest, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(label, "'") && strings.HasSuffix(label, "'") && len(label) >= 2 {
+			label = strings.ReplaceAll(label[1:len(label)-1], "''", "'")
+		}
+		fields := strings.Split(rest, ";")
+		num, uom := splitUOM(fields[0])
+		value, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			continue
+		}
+		pd := m.PerfData{Label: label, Value: value, UOM: uom}
+		if len(fields) > 1 {
+			pd.Warn = fields[1]
+		}
+		if len(fields) > 2 {
+			pd.Crit = fields[2]
+		}
+		if len(fields) > 3 {
+			pd.Min = parseOptionalFloat(fields[3])
+		}
+		if len(fields) > 4 {
+			pd.Max = parseOptionalFloat(fields[4])
+		}
+		out = append(out, pd)
+	}
+	return out
+}
+
+func splitPerfItems(s string) []string {
+	var items []string
+	var cur strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\'':
+			// '' inside a quoted label is an escaped quote
+			if quoted && i+1 < len(s) && s[i+1] == '\'' {
+				cur.WriteString("''")
+				i++
+				continue
+			}
+			quoted = !quoted
+			cur.WriteByte(ch)
+		case (ch == ' ' || ch == '\t') && !quoted:
+			if cur.Len() > 0 {
+				items = append(items, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	if cur.Len() > 0 {
+		items = append(items, cur.String())
+	}
+	return items
+}
+
+// splitUOM separates "95.5%" into "95.5" and "%"
+func splitUOM(v string) (string, string) {
+	i := len(v)
+	for i > 0 && !strings.ContainsRune("0123456789.", rune(v[i-1])) {
+		i--
+	}
+	return v[:i], v[i:]
+}
+
+func parseOptionalFloat(v string) *float64 {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil
+	}
+	return &f
+}
+
+// parseJSONCheckOutput reads the JSON output mode. The status may be a state name or
+// a Nagios code; when it is missing the exit code decides.
+func parseJSONCheckOutput(out string, res *m.CheckResult) {
+	var doc struct {
+		Status   json.RawMessage `json:"status"`
+		Output   string          `json:"output"`
+		Perfdata []m.PerfData    `json:"perfdata"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &doc); err != nil {
+		res.Status = m.CheckUnknown
+		res.Output = "invalid JSON output: " + err.Error()
+		return
+	}
+	res.Status = nagiosStatus(res.ExitCode)
+	var code int
+	var name string
+	if json.Unmarshal(doc.Status, &code) == nil {
+		res.ExitCode = code
+		res.Status = nagiosStatus(code)
+	} else if json.Unmarshal(doc.Status, &name) == nil {
+		switch strings.ToUpper(name) {
+		case m.CheckOK:
+			res.ExitCode, res.Status = 0, m.CheckOK
+		case m.CheckWarning:
+			res.ExitCode, res.Status = 1, m.CheckWarning
+		case m.CheckCritical:
+			res.ExitCode, res.Status = 2, m.CheckCritical
+		default:
+			res.ExitCode, res.Status = 3, m.CheckUnknown
+		}
+	}
+	res.Output = doc.Output
+	if doc.Perfdata != nil {
+		res.Perfdata = doc.Perfdata
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+
+	m "monserv/internal/metrics"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+func TestParsePerfdata(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []m.PerfData
+	}{
+		{
+			name: "all fields",
+			in:   "time=0.012s;1;2;0.000000;10.000000",
+			want: []m.PerfData{{Label: "time", Value: 0.012, UOM: "s", Warn: "1", Crit: "2", Min: ptr(0), Max: ptr(10)}},
+		},
+		{
+			name: "quoted label with spaces",
+			in:   "'disk used /'=95.5%;80;90",
+			want: []m.PerfData{{Label: "disk used /", Value: 95.5, UOM: "%", Warn: "80", Crit: "90"}},
+		},
+		{
+			name: "escaped quote in label",
+			in:   "'it''s up'=1",
+			want: []m.PerfData{{Label: "it's up", Value: 1}},
+		},
+		{
+			name: "empty warn and crit",
+			in:   "rta=0.5ms;;;0",
+			want: []m.PerfData{{Label: "rta", Value: 0.5, UOM: "ms", Min: ptr(0)}},
+		},
+		{
+			name: "trailing separators",
+			in:   "pl=0%;; bytes=1024B;;;;",
+			want: []m.PerfData{{Label: "pl", Value: 0, UOM: "%"}, {Label: "bytes", Value: 1024, UOM: "B"}},
+		},
+		{
+			name: "ranges kept as text",
+			in:   "users=5;@10:20;~:30",
+			want: []m.PerfData{{Label: "users", Value: 5, Warn: "@10:20", Crit: "~:30"}},
+		},
+		{
+			name: "counter and negative value",
+			in:   "requests=123c\ttemp=-4.5",
+			want: []m.PerfData{{Label: "requests", Value: 123, UOM: "c"}, {Label: "temp", Value: -4.5}},
+		},
+		{
+			name: "unparseable items skipped",
+			in:   "load=U;5;10 noequals load1=0.52;5;10;0;",
+			want: []m.PerfData{{Label: "load1", Value: 0.52, Warn: "5", Crit: "10", Min: ptr(0)}},
+		},
+		{
+			name: "empty",
+			in:   "",
+			want: []m.PerfData{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePerfdata(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePerfdata(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNagiosOutput(t *testing.T) {
+	// long output with perfdata on the first line and again after the text
+	out := "DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968\n" +
+		"/ 15272 MB (77%);\n" +
+		"/boot 68 MB (69%); | /boot=68MB;88;93;0;98\n" +
+		"/home=69357MB;253404;253409;0;253414\n"
+	text, perf := parseNagiosOutput(out)
+	wantText := "DISK OK - free space: / 3326 MB (56%);\n/ 15272 MB (77%);\n/boot 68 MB (69%);"
+	if text != wantText {
+		t.Errorf("text = %q, want %q", text, wantText)
+	}
+	var labels []string
+	for _, pd := range perf {
+		labels = append(labels, pd.Label)
+	}
+	if want := []string{"/", "/boot", "/home"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("perfdata labels = %q, want %q", labels, want)
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{name: "shorter", in: "OK", n: 4, want: "OK"},
+		{name: "exact", in: "OK - up", n: 7, want: "OK - up"},
+		{name: "ascii", in: "CRITICAL", n: 4, want: "CRIT"},
+		{name: "two-byte rune split", in: "suhu é", n: 6, want: "suhu "},
+		{name: "three-byte rune split", in: "€€", n: 5, want: "€"},
+		{name: "four-byte rune split", in: "a🔥", n: 4, want: "a"},
+		{name: "cut after a rune", in: "é!", n: 2, want: "é"},
+		{name: "first rune split", in: "€", n: 2, want: ""},
+		{name: "zero", in: "OK", n: 0, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateOutput(tt.in, tt.n)
+			if got != tt.want {
+				t.Errorf("truncateOutput(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateOutput(%q, %d) = %q, not valid UTF-8", tt.in, tt.n, got)
+			}
+		})
+	}
+}
@@ -2,28 +2,79 @@ package agent
 
 import (
 	"context"
+	"os"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
 	"github.com/shirou/gopsutil/v3/process"
 
 	m "monserv/internal/metrics"
 )
 
+// Version is reported as the agent version in the host inventory, set at build time with
+// -ldflags "-X monserv/internal/agent.Version=1.2.0"
+var Version = "dev"
+
 type Collector struct {
 	TopNProcs int
 	Timeout   time.Duration
+	// CgroupRoot is where container cgroups are read from; empty disables container metrics
+	CgroupRoot string
+	// DockerSocket is the Docker Engine API socket used for names, images and restart counts; empty disables it
+	DockerSocket string
+	// Units is the watch list of systemd units, e.g. "postgresql.service"
+	Units []string
+	// Checks provides the latest plugin check results; nil when no checks are configured
+	Checks *CheckRunner
+	// ProcessWatches selects the processes that must be running
+	ProcessWatches []m.ProcessMatcher
+	// LogWatches provides the log match counters; nil when no log watches are configured
+	LogWatches *LogWatcher
+
+	// previous counter samples, used to derive per-second rates
+	mu               sync.Mutex
+	prevNet          map[string]m.NetInterface
+	prevNetAt        time.Time
+	prevDiskIO       map[string]m.DiskIO
+	prevDiskIOAt     time.Time
+	prevMem          m.Memory
+	prevMemAt        time.Time
+	prevContainers   map[string]m.Container
+	prevContainersAt time.Time
+	docker           *dockerAPI // created on first use, for the current DockerSocket
 }
 
 func NewCollector() *Collector {
-	return &Collector{TopNProcs: 5, Timeout: 5 * time.Second}
+	return &Collector{
+		TopNProcs:    5,
+		Timeout:      5 * time.Second,
+		CgroupRoot:   "/sys/fs/cgroup",
+		DockerSocket: "/var/run/docker.sock",
+	}
+}
+
+// collectErrors keeps the first error of each sub-collector, keyed by collector name
+type collectErrors map[string]string
+
+func (e collectErrors) add(name string, err error) {
+	if err == nil {
+		return
+	}
+	if _, ok := e[name]; !ok {
+		e[name] = err.Error()
+	}
 }
 
-// Collect gathers metrics for the current host
+// Collect gathers metrics for the current host. Sub-collector failures do not fail
+// the whole sample; they are reported in CollectionErrors instead.
 func (c *Collector) Collect(ctx context.Context) (*m.ServerMetrics, error) {
 	// Add timeout
 	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
@@ -31,41 +82,28 @@ func (c *Collector) Collect(ctx context.Context) (*m.ServerMetrics, error) {
 		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
 		defer cancel()
 	}
+	started := time.Now()
+	errs := collectErrors{}
 
-	hv, _ := host.InfoWithContext(ctx)
-
-	// CPU usage
-	cpuPercents, _ := cpu.PercentWithContext(ctx, 1*time.Second, false)
-	cpuInfo, _ := cpu.InfoWithContext(ctx)
-	cpuUsage := 0.0
-	if len(cpuPercents) > 0 {
-		cpuUsage = cpuPercents[0]
-	}
-	cpuCores, _ := cpu.CountsWithContext(ctx, true)
-	cpuModel := "unknown"
-	if len(cpuInfo) > 0 {
-		cpuModel = cpuInfo[0].ModelName
-	}
-	cpuMetrics := m.CPU{
-		Cores:       cpuCores,
-		UsedPercent: cpuUsage,
-		ModelName:   cpuModel,
+	hv, err := host.InfoWithContext(ctx)
+	errs.add("host", err)
+	if hv == nil {
+		hv = &host.InfoStat{}
 	}
 
-	vm, _ := mem.VirtualMemoryWithContext(ctx)
-	memory := m.Memory{}
-	if vm != nil {
-		memory = m.Memory{
-			Total:       vm.Total,
-			Used:        vm.Used,
-			Free:        vm.Available,
-			UsedPercent: vm.UsedPercent,
-		}
-	}
+	// Process CPU times are taken around the CPU sampling window
+	procTimesBefore := processCPUTimes(ctx)
+	windowStart := time.Now()
+	cpuMetrics, err := collectCPU(ctx)
+	errs.add("cpu", err)
+
+	memory, err := c.collectMemory(ctx)
+	errs.add("memory", err)
 
 	parts := []m.DiskPartition{}
 	// Use disk.Partitions to get mount points then disk.Usage per mount
-	p, _ := disk.PartitionsWithContext(ctx, true)
+	p, err := disk.PartitionsWithContext(ctx, true)
+	errs.add("disk", err)
 	for _, part := range p {
 		// Skip some virtual or unusual filesystems optionally
 		usage, err := disk.UsageWithContext(ctx, part.Mountpoint)
@@ -73,29 +111,59 @@ func (c *Collector) Collect(ctx context.Context) (*m.ServerMetrics, error) {
 			continue
 		}
 		parts = append(parts, m.DiskPartition{
-			Device:      part.Device,
-			Mountpoint:  part.Mountpoint,
-			Fstype:      part.Fstype,
-			Total:       usage.Total,
-			Used:        usage.Used,
-			Free:        usage.Free,
-			UsedPercent: usage.UsedPercent,
+			Device:            part.Device,
+			Mountpoint:        part.Mountpoint,
+			Fstype:            part.Fstype,
+			Total:             usage.Total,
+			Used:              usage.Used,
+			Free:              usage.Free,
+			UsedPercent:       usage.UsedPercent,
+			InodesTotal:       usage.InodesTotal,
+			InodesUsed:        usage.InodesUsed,
+			InodesFree:        usage.InodesFree,
+			InodesUsedPercent: usage.InodesUsedPercent,
 		})
 	}
 
-	// Processes by memory usage
+	diskIO, err := c.collectDiskIO(ctx)
+	errs.add("diskio", err)
+	network, err := c.collectNetwork(ctx)
+	errs.add("network", err)
+	sensors, err := collectSensors(ctx)
+	errs.add("sensors", err)
+	containers, err := c.collectContainers(ctx)
+	errs.add("containers", err)
+	units, err := c.collectUnits(ctx)
+	errs.add("systemd", err)
+	tcp, err := collectTCP(ctx)
+	errs.add("tcp", err)
+	pressure, err := collectPressure()
+	errs.add("pressure", err)
+
+	// Processes by memory and CPU usage
 	procsByMem := []m.ProcMem{}
-	procs, _ := process.ProcessesWithContext(ctx)
+	procsByCPU := []m.ProcCPU{}
+	window := time.Since(windowStart).Seconds()
+	procs, err := process.ProcessesWithContext(ctx)
+	errs.add("processes", err)
+	var watched []m.ProcessInfo
 	for _, p := range procs {
 		// Best-effort; ignore errors to avoid heavy failures
+		name, _ := p.NameWithContext(ctx)
+		user, _ := p.UsernameWithContext(ctx)
+		cmd, _ := p.CmdlineWithContext(ctx)
+		if len(c.ProcessWatches) > 0 {
+			info := m.ProcessInfo{PID: p.Pid, Comm: name, User: user, Cmdline: cmd}
+			if created, err := p.CreateTimeWithContext(ctx); err == nil {
+				info.UptimeSeconds = max(0, time.Since(time.UnixMilli(created)).Seconds())
+			}
+			watched = append(watched, info)
+		}
 		memInfo, err := p.MemoryInfoWithContext(ctx)
 		if err != nil || memInfo == nil {
 			continue
 		}
 		percent, _ := p.MemoryPercentWithContext(ctx)
-		name, _ := p.NameWithContext(ctx)
-		user, _ := p.UsernameWithContext(ctx)
-		cmd, _ := p.CmdlineWithContext(ctx)
 		procsByMem = append(procsByMem, m.ProcMem{
 			PID:        p.Pid,
 			Name:       name,
@@ -104,23 +172,317 @@ func (c *Collector) Collect(ctx context.Context) (*m.ServerMetrics, error) {
 			PercentRAM: percent,
 			Cmdline:    cmd,
 		})
+
+		before, ok := procTimesBefore[p.Pid]
+		if !ok || window <= 0 {
+			continue
+		}
+		times, err := p.TimesWithContext(ctx)
+		if err != nil || times == nil {
+			continue
+		}
+		procsByCPU = append(procsByCPU, m.ProcCPU{
+			PID:        p.Pid,
+			Name:       name,
+			Username:   user,
+			CPUPercent: max(0, (times.User+times.System-before)/window*100),
+			RSSBytes:   memInfo.RSS,
+			Cmdline:    cmd,
+		})
 	}
 	sort.Slice(procsByMem, func(i, j int) bool { return procsByMem[i].RSSBytes > procsByMem[j].RSSBytes })
 	if c.TopNProcs > 0 && len(procsByMem) > c.TopNProcs {
 		procsByMem = procsByMem[:c.TopNProcs]
 	}
+	sort.Slice(procsByCPU, func(i, j int) bool { return procsByCPU[i].CPUPercent > procsByCPU[j].CPUPercent })
+	if c.TopNProcs > 0 && len(procsByCPU) > c.TopNProcs {
+		procsByCPU = procsByCPU[:c.TopNProcs]
+	}
 
 	return &m.ServerMetrics{
 		Hostname:       firstNonEmpty(hv.Hostname, "unknown"),
 		UptimeSeconds:  uptime(hv),
+		Host:           hostInfo(hv),
 		CPU:            cpuMetrics,
 		Memory:         memory,
 		Disks:          parts,
+		DiskIO:         diskIO,
+		Network:        network,
+		Sensors:        sensors,
+		Containers:     containers,
+		SystemdUnits:   units,
+		Checks:         c.Checks.Results(),
+		LogWatches:     c.LogWatches.Results(),
+		ProcessWatches: m.WatchProcesses(c.ProcessWatches, watched),
+		TCP:            tcp,
+		Pressure:       pressure,
 		TopProcsByMem:  procsByMem,
+		TopProcsByCPU:  procsByCPU,
 		GeneratedAtUTC: time.Now().UTC(),
+		// Time spent collecting, including the one second CPU sampling window
+		CollectionDurationMs: float64(time.Since(started).Microseconds()) / 1000,
+		CollectionErrors:     errs,
 	}, nil
 }
 
+// collectTCP summarises the IPv4 and IPv6 TCP sockets; owners are only visible for
+// processes the agent may inspect, which usually means running as root
+func collectTCP(ctx context.Context) (m.TCPStats, error) {
+	stats, err := net.ConnectionsWithContext(ctx, "tcp")
+	if err != nil {
+		return m.NewTCPStats(nil), err
+	}
+	conns := make([]m.TCPConn, 0, len(stats))
+	for _, s := range stats {
+		conns = append(conns, m.TCPConn{
+			State:      s.Status,
+			LocalAddr:  s.Laddr.IP,
+			LocalPort:  s.Laddr.Port,
+			RemoteAddr: s.Raddr.IP,
+			RemotePort: s.Raddr.Port,
+			PID:        s.Pid,
+		})
+	}
+	tcp := m.NewTCPStats(conns)
+	for i, l := range tcp.Listening {
+		if l.PID <= 0 {
+			continue
+		}
+		if p, err := process.NewProcessWithContext(ctx, l.PID); err == nil {
+			tcp.Listening[i].Process, _ = p.NameWithContext(ctx)
+		}
+	}
+	return tcp, nil
+}
+
+// collectNetwork reads per-interface counters and derives rates from the previous call
+func (c *Collector) collectNetwork(ctx context.Context) ([]m.NetInterface, error) {
+	out := []m.NetInterface{}
+	counters, err := net.IOCountersWithContext(ctx, true)
+	if err != nil {
+		return out, err
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := now.Sub(c.prevNetAt)
+	cur := make(map[string]m.NetInterface, len(counters))
+	for _, io := range counters {
+		ni := m.NetInterface{
+			Name:        io.Name,
+			BytesRecv:   io.BytesRecv,
+			BytesSent:   io.BytesSent,
+			PacketsRecv: io.PacketsRecv,
+			PacketsSent: io.PacketsSent,
+			ErrIn:       io.Errin,
+			ErrOut:      io.Errout,
+			DropIn:      io.Dropin,
+			DropOut:     io.Dropout,
+		}
+		if prev, ok := c.prevNet[io.Name]; ok {
+			ni.ComputeRates(prev, elapsed)
+		}
+		cur[io.Name] = ni
+		out = append(out, ni)
+	}
+	c.prevNet = cur
+	c.prevNetAt = now
+	return out, nil
+}
+
+// collectCPU samples CPU times over one second and reads load averages
+func collectCPU(ctx context.Context) (m.CPU, error) {
+	cpuInfo, _ := cpu.InfoWithContext(ctx)
+	cpuCores, _ := cpu.CountsWithContext(ctx, true)
+	cpuModel := "unknown"
+	if len(cpuInfo) > 0 {
+		cpuModel = cpuInfo[0].ModelName
+	}
+	out := m.CPU{
+		Cores:     cpuCores,
+		ModelName: cpuModel,
+		PerCore:   []float64{},
+	}
+
+	before, err := cpu.TimesWithContext(ctx, false)
+	if err != nil {
+		return out, err
+	}
+	beforeCores, _ := cpu.TimesWithContext(ctx, true)
+	select {
+	case <-time.After(time.Second):
+	case <-ctx.Done():
+		return out, ctx.Err()
+	}
+	after, _ := cpu.TimesWithContext(ctx, false)
+	afterCores, _ := cpu.TimesWithContext(ctx, true)
+
+	if len(before) > 0 && len(after) > 0 {
+		out.SetTimes(toCPUTimes(after[0]), toCPUTimes(before[0]))
+	}
+	if len(beforeCores) == len(afterCores) {
+		for i := range afterCores {
+			out.PerCore = append(out.PerCore, m.BusyPercent(toCPUTimes(afterCores[i]), toCPUTimes(beforeCores[i])))
+		}
+	}
+
+	if avg, err := load.AvgWithContext(ctx); err == nil && avg != nil {
+		out.Load1, out.Load5, out.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+	return out, nil
+}
+
+// processCPUTimes returns the cumulative user+system CPU seconds of every process
+func processCPUTimes(ctx context.Context) map[int32]float64 {
+	out := map[int32]float64{}
+	procs, _ := process.ProcessesWithContext(ctx)
+	for _, p := range procs {
+		if t, err := p.TimesWithContext(ctx); err == nil && t != nil {
+			out[p.Pid] = t.User + t.System
+		}
+	}
+	return out
+}
+
+// collectSensors reads hardware temperatures; hosts without sensors (most VMs) report none
+func collectSensors(ctx context.Context) ([]m.Sensor, error) {
+	out := []m.Sensor{}
+	// A partial result comes with a warnings error, so keep whatever was read
+	// and only report the error when nothing could be read at all
+	temps, err := host.SensorsTemperaturesWithContext(ctx)
+	if len(temps) > 0 {
+		err = nil
+	}
+	for _, t := range temps {
+		out = append(out, m.Sensor{
+			Name:         t.SensorKey,
+			TemperatureC: t.Temperature,
+			HighC:        t.High,
+			CriticalC:    t.Critical,
+		})
+	}
+	return out, err
+}
+
+func toCPUTimes(t cpu.TimesStat) m.CPUTimes {
+	return m.CPUTimes{
+		User:    t.User,
+		Nice:    t.Nice,
+		System:  t.System,
+		Idle:    t.Idle,
+		IOWait:  t.Iowait,
+		IRQ:     t.Irq,
+		SoftIRQ: t.Softirq,
+		Steal:   t.Steal,
+	}
+}
+
+// collectMemory reads RAM and swap usage and derives swap rates from the previous call
+func (c *Collector) collectMemory(ctx context.Context) (m.Memory, error) {
+	memory := m.Memory{}
+	vm, err := mem.VirtualMemoryWithContext(ctx)
+	if err != nil {
+		return memory, err
+	}
+	if vm != nil {
+		memory = m.Memory{
+			Total:          vm.Total,
+			Used:           vm.Used,
+			Free:           vm.Available,
+			UsedPercent:    vm.UsedPercent,
+			Buffers:        vm.Buffers,
+			Cached:         vm.Cached,
+			Shared:         vm.Shared,
+			HugePagesTotal: vm.HugePagesTotal,
+			HugePagesFree:  vm.HugePagesFree,
+			HugePageSize:   vm.HugePageSize,
+		}
+	}
+	// best effort: kernels before 4.13 have no oom_kill counter
+	if vmstat, err := os.ReadFile("/proc/vmstat"); err == nil {
+		memory.OOMKills = m.ParseOOMKills(string(vmstat))
+	}
+	sw, err := mem.SwapMemoryWithContext(ctx)
+	if err != nil || sw == nil {
+		return memory, err
+	}
+	memory.SwapTotal = sw.Total
+	memory.SwapUsed = sw.Used
+	memory.SwapFree = sw.Free
+	memory.SwapUsedPercent = sw.UsedPercent
+	memory.SwapIn = sw.Sin
+	memory.SwapOut = sw.Sout
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.prevMemAt.IsZero() {
+		memory.ComputeSwapRates(c.prevMem, now.Sub(c.prevMemAt))
+	}
+	c.prevMem = memory
+	c.prevMemAt = now
+	return memory, nil
+}
+
+// collectDiskIO reads per-device I/O counters and derives rates from the previous call
+func (c *Collector) collectDiskIO(ctx context.Context) ([]m.DiskIO, error) {
+	out := []m.DiskIO{}
+	counters, err := disk.IOCountersWithContext(ctx)
+	if err != nil {
+		return out, err
+	}
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := now.Sub(c.prevDiskIOAt)
+	cur := make(map[string]m.DiskIO, len(counters))
+	for name, io := range counters {
+		// loop and ram devices only add noise
+		if strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") {
+			continue
+		}
+		d := m.DiskIO{
+			Device:      name,
+			ReadCount:   io.ReadCount,
+			WriteCount:  io.WriteCount,
+			ReadBytes:   io.ReadBytes,
+			WriteBytes:  io.WriteBytes,
+			ReadTimeMs:  io.ReadTime,
+			WriteTimeMs: io.WriteTime,
+			IoTimeMs:    io.IoTime,
+		}
+		if prev, ok := c.prevDiskIO[name]; ok {
+			d.ComputeRates(prev, elapsed)
+		}
+		cur[name] = d
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Device < out[j].Device })
+	c.prevDiskIO = cur
+	c.prevDiskIOAt = now
+	return out, nil
+}
+
+func hostInfo(h *host.InfoStat) m.HostInfo {
+	info := m.HostInfo{
+		OS:                   h.OS,
+		Platform:             h.Platform,
+		PlatformFamily:       h.PlatformFamily,
+		PlatformVersion:      h.PlatformVersion,
+		KernelVersion:        h.KernelVersion,
+		Arch:                 h.KernelArch,
+		VirtualizationSystem: h.VirtualizationSystem,
+		VirtualizationRole:   h.VirtualizationRole,
+		AgentVersion:         Version,
+	}
+	if h.BootTime > 0 {
+		info.BootTimeUTC = time.Unix(int64(h.BootTime), 0).UTC()
+	}
+	return info
+}
+
 func uptime(h *host.InfoStat) uint64 {
 	if h == nil {
 		return 0
@@ -0,0 +1,352 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	m "monserv/internal/metrics"
+)
+
+// containerIDPattern matches the 64 hex digit Docker container ID inside cgroup directory names
+// such as "docker/<id>" (cgroupfs driver) or "system.slice/docker-<id>.scope" (systemd driver)
+var containerIDPattern = regexp.MustCompile(`(?:^|docker-|docker/)([0-9a-f]{64})(?:\.scope)?$`)
+
+// cgroupStats is the raw accounting read from a container cgroup
+type cgroupStats struct {
+	cpuUsageNs uint64
+	memUsage   uint64
+	memLimit   uint64
+	ioRead     uint64
+	ioWrite    uint64
+}
+
+// collectContainers reads per-container cgroup accounting, enriches it with names,
+// images, state and restart counts from the Docker Engine API when the socket is
+// reachable, and derives CPU and I/O rates from the previous call.
+// A missing Docker socket is not an error; hosts without Docker are common.
+func (c *Collector) collectContainers(ctx context.Context) ([]m.Container, error) {
+	out := []m.Container{}
+	if c.CgroupRoot == "" {
+		return out, nil
+	}
+	stats := readContainerCgroups(c.CgroupRoot)
+	byID := map[string]*m.Container{}
+	for id, st := range stats {
+		ct := &m.Container{
+			ID:           id,
+			Name:         id[:12],
+			State:        "running",
+			CPUUsageNs:   st.cpuUsageNs,
+			MemoryUsage:  st.memUsage,
+			MemoryLimit:  st.memLimit,
+			IOReadBytes:  st.ioRead,
+			IOWriteBytes: st.ioWrite,
+		}
+		if st.memLimit > 0 {
+			ct.MemoryPercent = float64(st.memUsage) / float64(st.memLimit) * 100
+		}
+		byID[id] = ct
+	}
+
+	var apiErr error
+	if c.DockerSocket != "" {
+		c.mu.Lock()
+		if c.docker == nil || c.docker.socket != c.DockerSocket {
+			if c.docker != nil {
+				c.docker.client.CloseIdleConnections()
+			}
+			c.docker = newDockerAPI(c.DockerSocket)
+		}
+		api := c.docker
+		// a cgroup CPU counter that went backwards belongs to a container restarted since
+		restarted := map[string]bool{}
+		for id, st := range stats {
+			if prev, ok := c.prevContainers[id]; ok && st.cpuUsageNs < prev.CPUUsageNs {
+				restarted[id] = true
+			}
+		}
+		c.mu.Unlock()
+		infos, err := api.containers(ctx, restarted)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			apiErr = err
+		}
+		if err == nil {
+			for _, info := range infos {
+				ct, ok := byID[info.ID]
+				if !ok {
+					// stopped containers have no cgroup but are still worth reporting
+					ct = &m.Container{ID: info.ID}
+					byID[info.ID] = ct
+				}
+				ct.Name = info.Name
+				ct.Image = info.Image
+				ct.State = info.State
+				ct.RestartCount = info.RestartCount
+			}
+		}
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elapsed := now.Sub(c.prevContainersAt)
+	cur := make(map[string]m.Container, len(byID))
+	for id, ct := range byID {
+		if prev, ok := c.prevContainers[id]; ok {
+			ct.ComputeRates(prev, elapsed)
+		}
+		cur[id] = *ct
+		out = append(out, *ct)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	c.prevContainers = cur
+	c.prevContainersAt = now
+	return out, apiErr
+}
+
+// readContainerCgroups finds Docker container cgroups below root and reads their
+// accounting, supporting both the unified (v2) and the legacy (v1) hierarchy
+func readContainerCgroups(root string) map[string]cgroupStats {
+	out := map[string]cgroupStats{}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		for id, rel := range containerCgroupDirs(root) {
+			out[id] = readCgroupV2(filepath.Join(root, rel))
+		}
+		return out
+	}
+	// v1 keeps one tree per controller; the memory tree is used for discovery
+	for id, rel := range containerCgroupDirs(filepath.Join(root, "memory")) {
+		out[id] = readCgroupV1(root, rel)
+	}
+	return out
+}
+
+// containerCgroupDirs returns container cgroup directories relative to base, keyed by container ID
+func containerCgroupDirs(base string) map[string]string {
+	dirs := map[string]string{}
+	for _, pattern := range []string{"docker/*", "system.slice/docker-*.scope"} {
+		matches, _ := filepath.Glob(filepath.Join(base, pattern))
+		for _, dir := range matches {
+			rel, err := filepath.Rel(base, dir)
+			if err != nil {
+				continue
+			}
+			if sm := containerIDPattern.FindStringSubmatch(filepath.ToSlash(rel)); sm != nil {
+				dirs[sm[1]] = rel
+			}
+		}
+	}
+	return dirs
+}
+
+func readCgroupV2(dir string) cgroupStats {
+	var st cgroupStats
+	cpuStat := readKeyValues(filepath.Join(dir, "cpu.stat"))
+	st.cpuUsageNs = cpuStat["usage_usec"] * 1000
+	// like docker stats, inactive page cache is not counted as used
+	st.memUsage = subFloor(readUint(filepath.Join(dir, "memory.current")), readKeyValues(filepath.Join(dir, "memory.stat"))["inactive_file"])
+	st.memLimit = readUint(filepath.Join(dir, "memory.max")) // "max" parses as 0, meaning unlimited
+
+	// io.stat: "8:0 rbytes=1234 wbytes=5678 rios=1 wios=2 dbytes=0 dios=0"
+	for _, line := range readLines(filepath.Join(dir, "io.stat")) {
+		for _, kv := range strings.Fields(line)[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			n, _ := strconv.ParseUint(v, 10, 64)
+			switch k {
+			case "rbytes":
+				st.ioRead += n
+			case "wbytes":
+				st.ioWrite += n
+			}
+		}
+	}
+	return st
+}
+
+func readCgroupV1(root, rel string) cgroupStats {
+	var st cgroupStats
+	st.cpuUsageNs = readUint(filepath.Join(root, "cpuacct", rel, "cpuacct.usage"))
+	memDir := filepath.Join(root, "memory", rel)
+	st.memUsage = subFloor(readUint(filepath.Join(memDir, "memory.usage_in_bytes")), readKeyValues(filepath.Join(memDir, "memory.stat"))["total_inactive_file"])
+	// an unlimited cgroup reports a page-rounded MaxInt64
+	if limit := readUint(filepath.Join(memDir, "memory.limit_in_bytes")); limit < 1<<62 {
+		st.memLimit = limit
+	}
+
+	// blkio.throttle.io_service_bytes: "8:0 Read 1234" ... "Total 5678"
+	for _, line := range readLines(filepath.Join(root, "blkio", rel, "blkio.throttle.io_service_bytes")) {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		n, _ := strconv.ParseUint(f[2], 10, 64)
+		switch f[1] {
+		case "Read":
+			st.ioRead += n
+		case "Write":
+			st.ioWrite += n
+		}
+	}
+	return st
+}
+
+func readLines(path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(string(b)))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// readKeyValues parses flat "key value" files such as cpu.stat and memory.stat
+func readKeyValues(path string) map[string]uint64 {
+	out := map[string]uint64{}
+	for _, line := range readLines(path) {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		if n, err := strconv.ParseUint(f[1], 10, 64); err == nil {
+			out[f[0]] = n
+		}
+	}
+	return out
+}
+
+func readUint(path string) uint64 {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	return n
+}
+
+func subFloor(a, b uint64) uint64 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// dockerContainer is the subset of the Docker Engine API container info used for enrichment
+type dockerContainer struct {
+	ID           string
+	Name         string
+	Image        string
+	State        string
+	RestartCount int
+}
+
+// dockerAPI is a Docker Engine API client on a unix socket. Its connections stay open
+// across samples, and inspect results are cached per container until the container changes.
+type dockerAPI struct {
+	socket string
+	client *http.Client
+
+	mu      sync.Mutex
+	inspect map[string]dockerInspect // by container ID
+}
+
+// dockerInspect is the cached part of a container inspect result
+type dockerInspect struct {
+	state        string // list state the result was fetched in
+	restartCount int
+}
+
+func newDockerAPI(socket string) *dockerAPI {
+	return &dockerAPI{
+		socket: socket,
+		client: &http.Client{
+			Timeout: 2 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+		inspect: map[string]dockerInspect{},
+	}
+}
+
+// containers lists all containers. Restart counts are only available from the inspect
+// endpoint, which is called for new containers, those whose state changed and those in
+// restarted, and cached for the others.
+func (d *dockerAPI) containers(ctx context.Context, restarted map[string]bool) ([]dockerContainer, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	get := func(path string, v any) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("docker api %s: status %d", path, resp.StatusCode)
+		}
+		return json.NewDecoder(resp.Body).Decode(v)
+	}
+
+	var list []struct {
+		ID    string   `json:"Id"`
+		Names []string `json:"Names"`
+		Image string   `json:"Image"`
+		State string   `json:"State"`
+	}
+	if err := get("/containers/json?all=1", &list); err != nil {
+		return nil, err
+	}
+	out := make([]dockerContainer, 0, len(list))
+	listed := make(map[string]bool, len(list))
+	for _, c := range list {
+		dc := dockerContainer{ID: c.ID, Name: c.ID[:min(12, len(c.ID))], Image: c.Image, State: c.State}
+		if len(c.Names) > 0 {
+			dc.Name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		listed[c.ID] = true
+		cached, ok := d.inspect[c.ID]
+		if !ok || cached.state != c.State || restarted[c.ID] {
+			var inspect struct {
+				RestartCount int `json:"RestartCount"`
+			}
+			if err := get("/containers/"+c.ID+"/json", &inspect); err == nil {
+				cached = dockerInspect{state: c.State, restartCount: inspect.RestartCount}
+				d.inspect[c.ID] = cached
+			}
+		}
+		dc.RestartCount = cached.restartCount
+		out = append(out, dc)
+	}
+	for id := range d.inspect {
+		if !listed[id] {
+			delete(d.inspect, id)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,366 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	m "monserv/internal/metrics"
+)
+
+const (
+	// maxLogReadPerPoll bounds how much of one file is read per poll, the rest follows on the next one
+	maxLogReadPerPoll = 8 << 20
+	// maxLogLine caps the length of a buffered line, maxKeptLine that of a reported matching line
+	maxLogLine  = 4096
+	maxKeptLine = 512
+)
+
+// LogWatchConfig describes one log watch, as read from the log watch file:
+//
+//	[{"name": "scada-conn", "paths": ["/var/log/scada/*.log"], "pattern": "CONNECTION LOST",
+//	  "windowSeconds": 60, "threshold": 5, "keepLines": 5}]
+//
+// Paths are glob patterns, re-expanded on every poll so new and rotated files are picked up.
+type LogWatchConfig struct {
+	Name          string   `json:"name"`
+	Paths         []string `json:"paths"`
+	Pattern       string   `json:"pattern"`
+	WindowSeconds int      `json:"windowSeconds"`
+	Threshold     int      `json:"threshold"`
+	KeepLines     int      `json:"keepLines"`
+}
+
+// LoadLogWatches reads log watch definitions from a JSON file and applies defaults
+func LoadLogWatches(path string) ([]LogWatchConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var watches []LogWatchConfig
+	if err := json.Unmarshal(b, &watches); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i := range watches {
+		w := &watches[i]
+		if w.Name == "" || len(w.Paths) == 0 || w.Pattern == "" {
+			return nil, fmt.Errorf("%s: log watch %d needs a name, paths and a pattern", path, i+1)
+		}
+		if seen[w.Name] {
+			return nil, fmt.Errorf("%s: duplicate log watch name %q", path, w.Name)
+		}
+		seen[w.Name] = true
+		if _, err := regexp.Compile(w.Pattern); err != nil {
+			return nil, fmt.Errorf("%s: log watch %q: %w", path, w.Name, err)
+		}
+		for _, p := range w.Paths {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("%s: log watch %q: bad path %q: %w", path, w.Name, p, err)
+			}
+		}
+		if w.WindowSeconds <= 0 {
+			w.WindowSeconds = 60
+		}
+		if w.KeepLines <= 0 {
+			w.KeepLines = 5
+		}
+	}
+	return watches, nil
+}
+
+// LogWatcher tails the files of every log watch and counts lines matching its pattern.
+// Files are followed by identity, so rename-and-create rotation, copytruncate and
+// deleted files are handled: a rotated file is read to its end before it is dropped.
+type LogWatcher struct {
+	PollInterval time.Duration
+
+	mu      sync.Mutex
+	watches []*logWatchState
+}
+
+type logWatchState struct {
+	cfg    LogWatchConfig
+	re     *regexp.Regexp
+	files  []*tailedFile
+	primed bool // files found on the first poll are tailed from their end
+
+	buckets []matchBucket
+	total   uint64
+	last    []m.LogLine
+	paths   []string
+	err     string
+}
+
+// matchBucket counts the matches seen within one second
+type matchBucket struct {
+	sec int64
+	n   int
+}
+
+type tailedFile struct {
+	path    string
+	f       *os.File
+	info    os.FileInfo
+	offset  int64
+	partial []byte
+}
+
+func NewLogWatcher(watches []LogWatchConfig) (*LogWatcher, error) {
+	lw := &LogWatcher{PollInterval: time.Second}
+	for _, cfg := range watches {
+		re, err := regexp.Compile(cfg.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("log watch %q: %w", cfg.Name, err)
+		}
+		lw.watches = append(lw.watches, &logWatchState{cfg: cfg, re: re})
+	}
+	return lw, nil
+}
+
+// Run polls the watched files until stop is closed
+func (lw *LogWatcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(lw.PollInterval)
+	defer ticker.Stop()
+	for {
+		for _, ws := range lw.watches {
+			lw.poll(ws)
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			for _, ws := range lw.watches {
+				for _, tf := range ws.files {
+					tf.f.Close()
+				}
+			}
+			return
+		}
+	}
+}
+
+// Results returns the current state of every log watch, sorted by name
+func (lw *LogWatcher) Results() []m.LogWatch {
+	out := []m.LogWatch{}
+	if lw == nil {
+		return out
+	}
+	now := time.Now().Unix()
+	lw.mu.Lock()
+	for _, ws := range lw.watches {
+		out = append(out, m.LogWatch{
+			Name:          ws.cfg.Name,
+			Pattern:       ws.cfg.Pattern,
+			Files:         append([]string{}, ws.paths...),
+			WindowSeconds: ws.cfg.WindowSeconds,
+			Matches:       ws.matchesSince(now - int64(ws.cfg.WindowSeconds)),
+			Threshold:     ws.cfg.Threshold,
+			TotalMatches:  ws.total,
+			LastLines:     append([]m.LogLine{}, ws.last...),
+			Error:         ws.err,
+		})
+	}
+	lw.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// poll matches the current glob results against the tailed files and reads what was appended
+func (lw *LogWatcher) poll(ws *logWatchState) {
+	var errs []error
+	type candidate struct {
+		path string
+		info os.FileInfo
+	}
+	var found []candidate
+	seenPath := map[string]bool{}
+	for _, pattern := range ws.cfg.Paths {
+		matches, _ := filepath.Glob(pattern) // patterns are validated on load
+		for _, p := range matches {
+			if seenPath[p] {
+				continue
+			}
+			seenPath[p] = true
+			info, err := os.Stat(p)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			if info.Mode().IsRegular() {
+				found = append(found, candidate{p, info})
+			}
+		}
+	}
+
+	// Follow known files to their current path; files no longer found were rotated away or deleted
+	used := make([]bool, len(found))
+	var kept []*tailedFile
+	for _, tf := range ws.files {
+		idx := -1
+		for i, c := range found {
+			if !used[i] && os.SameFile(tf.info, c.info) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			lw.read(ws, tf, true)
+			tf.f.Close()
+			continue
+		}
+		used[idx] = true
+		tf.path, tf.info = found[idx].path, found[idx].info
+		if tf.info.Size() < tf.offset {
+			// truncated in place (copytruncate)
+			tf.offset = 0
+			tf.partial = nil
+		}
+		if err := lw.read(ws, tf, false); err != nil {
+			errs = append(errs, err)
+		}
+		kept = append(kept, tf)
+	}
+
+	for i, c := range found {
+		if used[i] {
+			continue
+		}
+		f, err := os.Open(c.path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		tf := &tailedFile{path: c.path, f: f, info: c.info}
+		if !ws.primed {
+			tf.offset = c.info.Size()
+		}
+		if err := lw.read(ws, tf, false); err != nil {
+			errs = append(errs, err)
+		}
+		kept = append(kept, tf)
+	}
+	ws.files = kept
+	ws.primed = true
+
+	paths := make([]string, len(kept))
+	for i, tf := range kept {
+		paths[i] = tf.path
+	}
+	sort.Strings(paths)
+	lw.mu.Lock()
+	ws.paths = paths
+	ws.err = ""
+	if len(errs) > 0 {
+		ws.err = errors.Join(errs...).Error()
+	}
+	ws.prune(time.Now().Unix() - int64(ws.cfg.WindowSeconds))
+	lw.mu.Unlock()
+}
+
+// read consumes the data appended to tf since the last poll. When final is set the file is
+// about to be dropped and a trailing line without newline is matched as well.
+func (lw *LogWatcher) read(ws *logWatchState, tf *tailedFile, final bool) error {
+	buf := make([]byte, 64<<10)
+	var readTotal int64
+	for readTotal < maxLogReadPerPoll {
+		n, err := tf.f.ReadAt(buf, tf.offset)
+		if n > 0 {
+			tf.offset += int64(n)
+			readTotal += int64(n)
+			lw.consume(ws, tf, buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", tf.path, err)
+		}
+	}
+	if final && len(tf.partial) > 0 {
+		lw.match(ws, tf.path, tf.partial)
+		tf.partial = nil
+	}
+	return nil
+}
+
+func (lw *LogWatcher) consume(ws *logWatchState, tf *tailedFile, data []byte) {
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			tf.partial = appendCapped(tf.partial, data)
+			return
+		}
+		line := data[:i]
+		if len(tf.partial) > 0 {
+			line = appendCapped(tf.partial, line)
+			tf.partial = nil
+		}
+		lw.match(ws, tf.path, line)
+		data = data[i+1:]
+	}
+}
+
+func (lw *LogWatcher) match(ws *logWatchState, path string, line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if !ws.re.Match(line) {
+		return
+	}
+	now := time.Now()
+	text := string(line)
+	if len(text) > maxKeptLine {
+		text = text[:maxKeptLine]
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	sec := now.Unix()
+	if n := len(ws.buckets); n > 0 && ws.buckets[n-1].sec == sec {
+		ws.buckets[n-1].n++
+	} else {
+		ws.buckets = append(ws.buckets, matchBucket{sec: sec, n: 1})
+	}
+	ws.total++
+	ws.last = append(ws.last, m.LogLine{File: path, Text: text, SeenAtUTC: now.UTC()})
+	if len(ws.last) > ws.cfg.KeepLines {
+		ws.last = ws.last[len(ws.last)-ws.cfg.KeepLines:]
+	}
+}
+
+// prune drops the buckets at or before the given second
+func (ws *logWatchState) prune(before int64) {
+	i := 0
+	for i < len(ws.buckets) && ws.buckets[i].sec <= before {
+		i++
+	}
+	ws.buckets = ws.buckets[i:]
+}
+
+func (ws *logWatchState) matchesSince(after int64) int {
+	n := 0
+	for _, b := range ws.buckets {
+		if b.sec > after {
+			n += b.n
+		}
+	}
+	return n
+}
+
+// appendCapped appends data to a line buffer, dropping what exceeds maxLogLine
+func appendCapped(dst, data []byte) []byte {
+	if room := maxLogLine - len(dst); room < len(data) {
+		if room <= 0 {
+			return dst
+		}
+		data = data[:room]
+	}
+	return append(dst, data...)
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// appendLog appends data to the file at path, creating it when missing
+func appendLog(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLogWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "app.log")
+	appendLog(t, logPath, "ERROR before the agent started\n")
+
+	lw, err := NewLogWatcher([]LogWatchConfig{{
+		Name: "errors", Paths: []string{filepath.Join(dir, "*.log")}, Pattern: "ERROR",
+		WindowSeconds: 60, KeepLines: 5,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws := lw.watches[0]
+	t.Cleanup(func() {
+		for _, tf := range ws.files {
+			tf.f.Close()
+		}
+	})
+
+	// every step runs one poll after changing the files; offsets are keyed by file name
+	steps := []struct {
+		name        string
+		change      func(t *testing.T)
+		wantMatches int
+		wantOffsets map[string]int64
+		wantLast    string
+	}{
+		{
+			name:        "existing content is skipped",
+			change:      func(t *testing.T) {},
+			wantOffsets: map[string]int64{"app.log": 31},
+		},
+		{
+			name: "appended lines",
+			change: func(t *testing.T) {
+				appendLog(t, logPath, "ERROR one\nINFO ok\nERROR tw")
+			},
+			wantMatches: 1,
+			wantOffsets: map[string]int64{"app.log": 57},
+			wantLast:    "ERROR one",
+		},
+		{
+			name: "rename and create",
+			change: func(t *testing.T) {
+				// the line left open is completed after the rename and read from the old file
+				appendLog(t, logPath, "o\n")
+				if err := os.Rename(logPath, logPath+".1"); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(t, logPath, "ERROR three\n")
+			},
+			wantMatches: 3,
+			wantOffsets: map[string]int64{"app.log": 12},
+			wantLast:    "ERROR three",
+		},
+		{
+			name: "truncated in place",
+			change: func(t *testing.T) {
+				if err := os.Truncate(logPath, 0); err != nil {
+					t.Fatal(err)
+				}
+				appendLog(t, logPath, "ERROR four\n")
+			},
+			wantMatches: 4,
+			wantOffsets: map[string]int64{"app.log": 11},
+			wantLast:    "ERROR four",
+		},
+		{
+			name: "deleted with an unterminated line",
+			change: func(t *testing.T) {
+				appendLog(t, logPath, "ERROR five")
+				lw.poll(ws)
+				if err := os.Remove(logPath); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantMatches: 5,
+			wantOffsets: map[string]int64{},
+			wantLast:    "ERROR five",
+		},
+	}
+	for _, st := range steps {
+		st.change(t)
+		lw.poll(ws)
+		res := lw.Results()[0]
+		if res.Matches != st.wantMatches || res.TotalMatches != uint64(st.wantMatches) {
+			t.Errorf("%s: matches = %d, total %d, want %d", st.name, res.Matches, res.TotalMatches, st.wantMatches)
+		}
+		offsets := map[string]int64{}
+		for _, tf := range ws.files {
+			offsets[filepath.Base(tf.path)] = tf.offset
+		}
+		if !reflect.DeepEqual(offsets, st.wantOffsets) {
+			t.Errorf("%s: offsets = %v, want %v", st.name, offsets, st.wantOffsets)
+		}
+		var last string
+		if n := len(res.LastLines); n > 0 {
+			last = res.LastLines[n-1].Text
+		}
+		if last != st.wantLast {
+			t.Errorf("%s: last line = %q, want %q", st.name, last, st.wantLast)
+		}
+		if res.Error != "" {
+			t.Errorf("%s: error %q", st.name, res.Error)
+		}
+	}
+}
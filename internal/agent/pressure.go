@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	m "monserv/internal/metrics"
+)
+
+// pressureRoot holds one PSI file per resource on kernels 4.20 and later
+const pressureRoot = "/proc/pressure"
+
+// collectPressure reads the PSI averages. Kernels without PSI, or booted with psi=0,
+// are not an error: the result is nil and the pressure section is omitted.
+func collectPressure() (*m.Pressure, error) {
+	var p m.Pressure
+	var errs []error
+	found := false
+	for name, dst := range map[string]**m.PressureResource{"cpu": &p.CPU, "memory": &p.Memory, "io": &p.IO} {
+		b, err := os.ReadFile(filepath.Join(pressureRoot, name))
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if *dst = m.ParsePressure(string(b)); *dst != nil {
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.Join(errs...)
+	}
+	return &p, errors.Join(errs...)
+}
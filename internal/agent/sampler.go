@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	m "monserv/internal/metrics"
+)
+
+// Source provides metrics samples. A Collector samples on every call; a Sampler
+// returns its latest background snapshot.
+type Source interface {
+	Collect(ctx context.Context) (*m.ServerMetrics, error)
+}
+
+// Sampler refreshes metrics in the background on its own interval, so requests are
+// served from the latest snapshot and concurrent scrapes do not multiply the load
+type Sampler struct {
+	Collector *Collector
+	Interval  time.Duration
+
+	mu      sync.RWMutex
+	latest  *m.ServerMetrics
+	lastErr error
+	ready   chan struct{} // closed after the first sample
+}
+
+func NewSampler(c *Collector, interval time.Duration) *Sampler {
+	return &Sampler{Collector: c, Interval: interval, ready: make(chan struct{})}
+}
+
+// Run samples immediately and then once per interval until stop is closed
+func (s *Sampler) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	first := true
+	for {
+		met, err := s.Collector.Collect(context.Background())
+		if err != nil {
+			log.Printf("[SAMPLER] %v", err)
+		}
+		s.mu.Lock()
+		if met != nil {
+			s.latest = met
+		}
+		s.lastErr = err
+		s.mu.Unlock()
+		if first {
+			close(s.ready)
+			first = false
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Collect returns a copy of the latest snapshot with SampleAgeSeconds set.
+// Before the first sample completes it waits until ctx is done.
+func (s *Sampler) Collect(ctx context.Context) (*m.ServerMetrics, error) {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.latest == nil {
+		if s.lastErr != nil {
+			return nil, s.lastErr
+		}
+		return nil, errors.New("no sample available")
+	}
+	snap := *s.latest
+	snap.SampleAgeSeconds = time.Since(snap.GeneratedAtUTC).Seconds()
+	return &snap, nil
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	m "monserv/internal/metrics"
+)
+
+// unitProperties are the systemctl show properties read for each watched unit
+const unitProperties = "Id,LoadState,ActiveState,SubState,NRestarts"
+
+// collectUnits reports the state of the watched systemd units. Units that systemctl
+// does not know are reported with LoadState "not-found" rather than dropped.
+func (c *Collector) collectUnits(ctx context.Context) ([]m.SystemdUnit, error) {
+	out := []m.SystemdUnit{}
+	if len(c.Units) == 0 {
+		return out, nil
+	}
+	args := append([]string{"show", "--property=" + unitProperties, "--"}, c.Units...)
+	b, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+			msg, _, _ := strings.Cut(strings.TrimSpace(string(ee.Stderr)), "\n")
+			return out, fmt.Errorf("systemctl: %s", msg)
+		}
+		return out, err
+	}
+	return parseSystemctlShow(string(b)), nil
+}
+
+// parseSystemctlShow parses "Key=Value" blocks, one per unit separated by blank lines
+func parseSystemctlShow(out string) []m.SystemdUnit {
+	units := []m.SystemdUnit{}
+	var cur *m.SystemdUnit
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			cur = nil
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if cur == nil {
+			units = append(units, m.SystemdUnit{})
+			cur = &units[len(units)-1]
+		}
+		switch k {
+		case "Id":
+			cur.Name = v
+		case "LoadState":
+			cur.LoadState = v
+		case "ActiveState":
+			cur.ActiveState = v
+		case "SubState":
+			cur.SubState = v
+		case "NRestarts":
+			// not available before systemd 235
+			cur.Restarts, _ = strconv.Atoi(v)
+		}
+	}
+	return units
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+
+	m "monserv/internal/metrics"
+)
+
+func TestParseSystemctlShow(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want []m.SystemdUnit
+	}{
+		{
+			// systemctl prints the properties in its own order, not that of --property
+			name: "systemd 249",
+			out: "NRestarts=0\nId=nginx.service\nLoadState=loaded\nActiveState=active\nSubState=running\n\n" +
+				"NRestarts=3\nId=backup.service\nLoadState=loaded\nActiveState=failed\nSubState=failed\n\n" +
+				"Id=backup.timer\nLoadState=loaded\nActiveState=active\nSubState=waiting\n\n" +
+				"NRestarts=0\nId=missing.service\nLoadState=not-found\nActiveState=inactive\nSubState=dead\n",
+			want: []m.SystemdUnit{
+				{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+				{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 3},
+				{Name: "backup.timer", LoadState: "loaded", ActiveState: "active", SubState: "waiting"},
+				{Name: "missing.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+			},
+		},
+		{
+			name: "systemd 219 without NRestarts",
+			out:  "Id=sshd.service\nLoadState=loaded\nActiveState=activating\nSubState=auto-restart\n",
+			want: []m.SystemdUnit{
+				{Name: "sshd.service", LoadState: "loaded", ActiveState: "activating", SubState: "auto-restart"},
+			},
+		},
+		{
+			name: "extra blank lines and stray text",
+			out:  "\n\nId=cron.service\nLoadState=loaded\nActiveState=active\nSubState=running\nnot a property\n\n\n",
+			want: []m.SystemdUnit{
+				{Name: "cron.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			},
+		},
+		{
+			name: "empty",
+			out:  "",
+			want: []m.SystemdUnit{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseSystemctlShow(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSystemctlShow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
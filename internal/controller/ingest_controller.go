@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"monserv/internal/dto"
+	m "monserv/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxIngestBody caps a pushed sample; a full one is well below 1 MB even with many containers
+const maxIngestBody = 4 << 20
+
+// Ingester accepts metrics pushed by agents
+type Ingester interface {
+	Ingest(source string, met *m.ServerMetrics)
+}
+
+// IngestController handles metrics pushed by agents that the server cannot poll
+type IngestController struct {
+	ingester Ingester
+	token    string
+}
+
+func NewIngestController(ingester Ingester, token string) *IngestController {
+	return &IngestController{ingester: ingester, token: token}
+}
+
+// Ingest godoc
+// @Summary Push metrics from an agent
+// @Description Accept a metrics sample pushed by an agent running in push mode. The host is tracked as `push://<agent id>`,
+// @Description where the agent id is taken from the `X-Agent-ID` header or, if absent, the reported hostname.
+// @Tags Ingest
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param X-Agent-ID header string false "Agent identifier" example(substation-01)
+// @Param metrics body metrics.ServerMetrics true "Metrics sample"
+// @Success 200 {object} dto.APIResponse{data=dto.IngestResponse} "Metrics ingested"
+// @Failure 400 {object} dto.APIResponse "Invalid payload"
+// @Failure 401 {object} dto.APIResponse "Missing or invalid token"
+// @Failure 403 {object} dto.APIResponse "Ingestion disabled"
+// @Failure 413 {object} dto.APIResponse "Payload larger than 4 MiB"
+// @Router /v1/ingest [post]
+func (c *IngestController) Ingest(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxIngestBody)
+	var met m.ServerMetrics
+	if err := ctx.ShouldBindJSON(&met); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		ctx.JSON(status, dto.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	agentID := strings.TrimSpace(ctx.GetHeader("X-Agent-ID"))
+	if agentID == "" {
+		agentID = met.Hostname
+	}
+	if agentID == "" {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Error:   "agent id or hostname is required",
+		})
+		return
+	}
+
+	source := "push://" + agentID
+	c.ingester.Ingest(source, &met)
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Metrics ingested",
+		Data:    dto.IngestResponse{Source: source},
+	})
+}
+
+// requireToken rejects requests without the configured bearer token
+func (c *IngestController) requireToken(ctx *gin.Context) {
+	if c.token == "" {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, dto.APIResponse{
+			Success: false,
+			Error:   "ingestion is disabled, set INGEST_TOKEN on the server",
+		})
+		return
+	}
+	got, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(c.token)) != 1 {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Error:   "invalid or missing bearer token",
+		})
+		return
+	}
+	ctx.Next()
+}
+
+// RegisterRoutes registers the ingest endpoint
+func (c *IngestController) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/v1/ingest", c.requireToken, c.Ingest)
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// ParsePressure parses one /proc/pressure file:
+//
+//	some avg10=0.00 avg60=0.12 avg300=0.05 total=123456
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=4567
+//
+// It returns nil when the content has no "some" line.
+func ParsePressure(content string) *PressureResource {
+	var res PressureResource
+	found := false
+	sc := bufio.NewScanner(strings.NewReader(content))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+		var avg PressureAvg
+		for _, kv := range f[1:] {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "avg10":
+				avg.Avg10, _ = strconv.ParseFloat(v, 64)
+			case "avg60":
+				avg.Avg60, _ = strconv.ParseFloat(v, 64)
+			case "avg300":
+				avg.Avg300, _ = strconv.ParseFloat(v, 64)
+			case "total":
+				avg.TotalUs, _ = strconv.ParseUint(v, 10, 64)
+			}
+		}
+		switch f[0] {
+		case "some":
+			res.Some = avg
+			found = true
+		case "full":
+			res.Full = &avg
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &res
+}
+
+// Resource returns the pressure of "cpu", "memory" or "io"; nil when it is not reported
+func (p *Pressure) Resource(name string) *PressureResource {
+	if p == nil {
+		return nil
+	}
+	switch name {
+	case "cpu":
+		return p.CPU
+	case "memory":
+		return p.Memory
+	case "io":
+		return p.IO
+	}
+	return nil
+}
+
+// Avg60 returns the 60 second average of the "some" or "full" line and whether it is reported
+func (r *PressureResource) Avg60(kind string) (float64, bool) {
+	switch {
+	case r == nil:
+		return 0, false
+	case kind == "some":
+		return r.Some.Avg60, true
+	case kind == "full" && r.Full != nil:
+		return r.Full.Avg60, true
+	}
+	return 0, false
+}
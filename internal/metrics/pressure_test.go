@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePressure(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *PressureResource
+	}{
+		{
+			name:    "io",
+			content: "some avg10=0.12 avg60=0.06 avg300=0.01 total=8388899\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=5393407\n",
+			want: &PressureResource{
+				Some: PressureAvg{Avg10: 0.12, Avg60: 0.06, Avg300: 0.01, TotalUs: 8388899},
+				Full: &PressureAvg{TotalUs: 5393407},
+			},
+		},
+		{
+			// the cpu file has no "full" line before Linux 5.13
+			name:    "cpu on 5.4",
+			content: "some avg10=2.60 avg60=2.97 avg300=2.70 total=135754391\n",
+			want:    &PressureResource{Some: PressureAvg{Avg10: 2.6, Avg60: 2.97, Avg300: 2.7, TotalUs: 135754391}},
+		},
+		{
+			name:    "idle memory",
+			content: "some avg10=0.00 avg60=0.00 avg300=0.00 total=0\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			want:    &PressureResource{Full: &PressureAvg{}},
+		},
+		{
+			name:    "unknown fields ignored",
+			content: "some avg10=1.50 avg60=1.00 avg600=9.99 avg300=0.50 total=42\n",
+			want:    &PressureResource{Some: PressureAvg{Avg10: 1.5, Avg60: 1, Avg300: 0.5, TotalUs: 42}},
+		},
+		{
+			name:    "full only",
+			content: "full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+		},
+		{
+			name:    "empty",
+			content: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParsePressure(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePressure() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
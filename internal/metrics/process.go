@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// maxWatchPIDs caps the PIDs reported per process watch
+const maxWatchPIDs = 64
+
+// ProcessMatcher selects the processes of one watched program, as read from the
+// process watch file:
+//
+//	[{"name": "modbus-gw", "comm": "modbusd", "user": "scada", "minCount": 1},
+//	 {"name": "historian", "cmdline": "java .*historian\\.jar", "minCount": 2}]
+//
+// Comm is compared with the process name exactly, Cmdline is a regular expression
+// on the full command line. Empty fields match any process.
+type ProcessMatcher struct {
+	Name     string `json:"name"`
+	Comm     string `json:"comm"`
+	Cmdline  string `json:"cmdline"`
+	User     string `json:"user"`
+	MinCount int    `json:"minCount"`
+
+	cmdline *regexp.Regexp
+}
+
+// ProcessInfo is what process matchers are evaluated against
+type ProcessInfo struct {
+	PID           int32
+	Comm          string
+	User          string
+	Cmdline       string
+	UptimeSeconds float64
+}
+
+// LoadProcessMatchers reads process matchers from a JSON file and applies defaults
+func LoadProcessMatchers(path string) ([]ProcessMatcher, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var matchers []ProcessMatcher
+	if err := json.Unmarshal(b, &matchers); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i := range matchers {
+		pm := &matchers[i]
+		if pm.Name == "" || (pm.Comm == "" && pm.Cmdline == "" && pm.User == "") {
+			return nil, fmt.Errorf("%s: process watch %d needs a name and at least one of comm, cmdline, user", path, i+1)
+		}
+		if seen[pm.Name] {
+			return nil, fmt.Errorf("%s: duplicate process watch name %q", path, pm.Name)
+		}
+		seen[pm.Name] = true
+		if pm.Cmdline != "" {
+			if pm.cmdline, err = regexp.Compile(pm.Cmdline); err != nil {
+				return nil, fmt.Errorf("%s: process watch %q: %w", path, pm.Name, err)
+			}
+		}
+		if pm.MinCount <= 0 {
+			pm.MinCount = 1
+		}
+	}
+	return matchers, nil
+}
+
+// Matches reports whether the process is selected by the matcher
+func (pm ProcessMatcher) Matches(p ProcessInfo) bool {
+	if pm.Comm != "" && p.Comm != pm.Comm {
+		return false
+	}
+	if pm.User != "" && p.User != pm.User {
+		return false
+	}
+	if pm.Cmdline != "" {
+		re := pm.cmdline
+		if re == nil {
+			// matcher built without LoadProcessMatchers
+			var err error
+			if re, err = regexp.Compile(pm.Cmdline); err != nil {
+				return false
+			}
+		}
+		if !re.MatchString(p.Cmdline) {
+			return false
+		}
+	}
+	return true
+}
+
+// WatchProcesses evaluates every matcher against the process list
+func WatchProcesses(matchers []ProcessMatcher, procs []ProcessInfo) []ProcessWatch {
+	out := []ProcessWatch{}
+	for _, pm := range matchers {
+		w := ProcessWatch{Name: pm.Name, MinCount: pm.MinCount, PIDs: []int32{}}
+		for _, p := range procs {
+			if !pm.Matches(p) {
+				continue
+			}
+			if w.Count == 0 || p.UptimeSeconds < w.UptimeSeconds {
+				w.UptimeSeconds = p.UptimeSeconds
+			}
+			w.Count++
+			w.PIDs = append(w.PIDs, p.PID)
+		}
+		sort.Slice(w.PIDs, func(a, b int) bool { return w.PIDs[a] < w.PIDs[b] })
+		if len(w.PIDs) > maxWatchPIDs {
+			w.PIDs = w.PIDs[:maxWatchPIDs]
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+// Missing reports whether fewer processes than required are running
+func (w ProcessWatch) Missing() bool {
+	return w.Count < w.MinCount
+}
+
+// Restarted reports whether a matched process was replaced since prev: one of the
+// previous PIDs is gone and a new one appeared. Watches with truncated PID lists
+// are not compared.
+func (w ProcessWatch) Restarted(prev ProcessWatch) bool {
+	if prev.Count == 0 || w.Count == 0 || len(prev.PIDs) < prev.Count || len(w.PIDs) < w.Count {
+		return false
+	}
+	before := map[int32]bool{}
+	for _, pid := range prev.PIDs {
+		before[pid] = true
+	}
+	appeared := false
+	for _, pid := range w.PIDs {
+		if !before[pid] {
+			appeared = true
+		}
+		delete(before, pid)
+	}
+	// anything left in before has exited
+	return appeared && len(before) > 0
+}
@@ -0,0 +1,348 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType is the media type of the text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promSample is one line of a metric family; labels are name/value pairs
+type promSample struct {
+	labels []string
+	value  float64
+}
+
+func sample(value float64, labels ...string) promSample {
+	return promSample{labels: labels, value: value}
+}
+
+type promWriter struct {
+	w *bufio.Writer
+}
+
+// family writes HELP and TYPE followed by the samples. Families without samples are skipped.
+func (p promWriter) family(name, typ, help string, samples ...promSample) {
+	if len(samples) == 0 {
+		return
+	}
+	p.w.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	p.w.WriteString("# TYPE " + name + " " + typ + "\n")
+	for _, s := range samples {
+		p.w.WriteString(name)
+		if len(s.labels) > 0 {
+			p.w.WriteByte('{')
+			for i := 0; i+1 < len(s.labels); i += 2 {
+				if i > 0 {
+					p.w.WriteByte(',')
+				}
+				p.w.WriteString(s.labels[i] + `="` + escapeLabel(s.labels[i+1]) + `"`)
+			}
+			p.w.WriteByte('}')
+		}
+		p.w.WriteString(" " + formatValue(s.value) + "\n")
+	}
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// float32Value widens without exposing float32 rounding noise such as 1.2000000476837158
+func float32Value(f float32) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return v
+}
+
+func sortedKeys[V any](mp map[string]V) []string {
+	keys := make([]string, 0, len(mp))
+	for k := range mp {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WritePrometheus renders the metrics in the Prometheus text exposition format.
+// Metric names are prefixed with "monserv_"; the hostname is exposed once through monserv_host_info.
+func (sm *ServerMetrics) WritePrometheus(out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	p := promWriter{w: bw}
+
+	p.family("monserv_host_info", "gauge", "Host information, always 1.",
+		sample(1, "hostname", sm.Hostname, "os", sm.Host.OS, "platform", sm.Host.Platform,
+			"platform_version", sm.Host.PlatformVersion, "kernel_version", sm.Host.KernelVersion,
+			"arch", sm.Host.Arch, "virtualization", sm.Host.VirtualizationSystem,
+			"virtualization_role", sm.Host.VirtualizationRole, "agent_version", sm.Host.AgentVersion))
+	p.family("monserv_uptime_seconds", "gauge", "Host uptime in seconds.",
+		sample(float64(sm.UptimeSeconds)))
+	if !sm.Host.BootTimeUTC.IsZero() {
+		p.family("monserv_boot_time_seconds", "gauge", "Host boot time as a Unix timestamp.",
+			sample(float64(sm.Host.BootTimeUTC.Unix())))
+	}
+	p.family("monserv_collection_duration_seconds", "gauge", "Time taken to collect the sample.",
+		sample(sm.CollectionDurationMs/1000))
+	p.family("monserv_sample_age_seconds", "gauge", "Age of the served sample.",
+		sample(sm.SampleAgeSeconds))
+	collectorErrs := make([]promSample, 0, len(sm.CollectionErrors))
+	for _, name := range sortedKeys(sm.CollectionErrors) {
+		collectorErrs = append(collectorErrs, sample(1, "collector", name))
+	}
+	p.family("monserv_collector_error", "gauge", "Sub-collectors that failed in the sample.", collectorErrs...)
+
+	// CPU
+	p.family("monserv_cpu_cores", "gauge", "Number of logical CPU cores.",
+		sample(float64(sm.CPU.Cores)))
+	p.family("monserv_cpu_used_percent", "gauge", "CPU busy percent across all cores.",
+		sample(sm.CPU.UsedPercent))
+	perCore := make([]promSample, len(sm.CPU.PerCore))
+	for i, v := range sm.CPU.PerCore {
+		perCore[i] = sample(v, "core", strconv.Itoa(i))
+	}
+	p.family("monserv_cpu_core_used_percent", "gauge", "CPU busy percent per core.", perCore...)
+	p.family("monserv_cpu_state_percent", "gauge", "Share of CPU time spent in each state.",
+		sample(sm.CPU.UserPercent, "state", "user"),
+		sample(sm.CPU.NicePercent, "state", "nice"),
+		sample(sm.CPU.SystemPercent, "state", "system"),
+		sample(sm.CPU.IdlePercent, "state", "idle"),
+		sample(sm.CPU.IOWaitPercent, "state", "iowait"),
+		sample(sm.CPU.IRQPercent, "state", "irq"),
+		sample(sm.CPU.SoftIRQPercent, "state", "softirq"),
+		sample(sm.CPU.StealPercent, "state", "steal"))
+	p.family("monserv_load1", "gauge", "1 minute load average.", sample(sm.CPU.Load1))
+	p.family("monserv_load5", "gauge", "5 minute load average.", sample(sm.CPU.Load5))
+	p.family("monserv_load15", "gauge", "15 minute load average.", sample(sm.CPU.Load15))
+
+	// Memory
+	mem := sm.Memory
+	p.family("monserv_memory_total_bytes", "gauge", "Total physical memory in bytes.", sample(float64(mem.Total)))
+	p.family("monserv_memory_used_bytes", "gauge", "Used physical memory in bytes, excluding page cache.", sample(float64(mem.Used)))
+	p.family("monserv_memory_available_bytes", "gauge", "Memory available to new applications in bytes.", sample(float64(mem.Free)))
+	p.family("monserv_memory_used_percent", "gauge", "Used physical memory percent.", sample(mem.UsedPercent))
+	p.family("monserv_memory_buffers_bytes", "gauge", "Memory used by kernel buffers in bytes.", sample(float64(mem.Buffers)))
+	p.family("monserv_memory_cached_bytes", "gauge", "Memory used by the page cache in bytes.", sample(float64(mem.Cached)))
+	p.family("monserv_memory_shared_bytes", "gauge", "Shared memory in bytes.", sample(float64(mem.Shared)))
+	p.family("monserv_swap_total_bytes", "gauge", "Total swap in bytes.", sample(float64(mem.SwapTotal)))
+	p.family("monserv_swap_used_bytes", "gauge", "Used swap in bytes.", sample(float64(mem.SwapUsed)))
+	p.family("monserv_swap_used_percent", "gauge", "Used swap percent.", sample(mem.SwapUsedPercent))
+	p.family("monserv_swap_in_bytes_total", "counter", "Bytes swapped in since boot.", sample(float64(mem.SwapIn)))
+	p.family("monserv_swap_out_bytes_total", "counter", "Bytes swapped out since boot.", sample(float64(mem.SwapOut)))
+	p.family("monserv_oom_kills_total", "counter", "Processes killed by the OOM killer since boot.", sample(float64(mem.OOMKills)))
+
+	// Filesystems
+	var fsSize, fsUsed, fsFree, fsPct, fsInodes, fsInodesUsed []promSample
+	// stacked mounts can list the same filesystem twice; duplicate series fail the whole scrape
+	seenFS := map[[3]string]bool{}
+	for _, d := range sm.Disks {
+		id := [3]string{d.Device, d.Mountpoint, d.Fstype}
+		if seenFS[id] {
+			continue
+		}
+		seenFS[id] = true
+		l := []string{"device", d.Device, "mountpoint", d.Mountpoint, "fstype", d.Fstype}
+		fsSize = append(fsSize, sample(float64(d.Total), l...))
+		fsUsed = append(fsUsed, sample(float64(d.Used), l...))
+		fsFree = append(fsFree, sample(float64(d.Free), l...))
+		fsPct = append(fsPct, sample(d.UsedPercent, l...))
+		if d.InodesTotal > 0 {
+			fsInodes = append(fsInodes, sample(float64(d.InodesTotal), l...))
+			fsInodesUsed = append(fsInodesUsed, sample(float64(d.InodesUsed), l...))
+		}
+	}
+	p.family("monserv_filesystem_size_bytes", "gauge", "Filesystem size in bytes.", fsSize...)
+	p.family("monserv_filesystem_used_bytes", "gauge", "Filesystem used space in bytes.", fsUsed...)
+	p.family("monserv_filesystem_free_bytes", "gauge", "Filesystem free space in bytes.", fsFree...)
+	p.family("monserv_filesystem_used_percent", "gauge", "Filesystem used space percent.", fsPct...)
+	p.family("monserv_filesystem_inodes", "gauge", "Filesystem total inodes.", fsInodes...)
+	p.family("monserv_filesystem_inodes_used", "gauge", "Filesystem used inodes.", fsInodesUsed...)
+
+	// Block devices
+	var ioRead, ioWrite, ioReads, ioWrites, ioTime []promSample
+	for _, d := range sm.DiskIO {
+		ioRead = append(ioRead, sample(float64(d.ReadBytes), "device", d.Device))
+		ioWrite = append(ioWrite, sample(float64(d.WriteBytes), "device", d.Device))
+		ioReads = append(ioReads, sample(float64(d.ReadCount), "device", d.Device))
+		ioWrites = append(ioWrites, sample(float64(d.WriteCount), "device", d.Device))
+		ioTime = append(ioTime, sample(float64(d.IoTimeMs)/1000, "device", d.Device))
+	}
+	p.family("monserv_disk_read_bytes_total", "counter", "Bytes read from the device.", ioRead...)
+	p.family("monserv_disk_written_bytes_total", "counter", "Bytes written to the device.", ioWrite...)
+	p.family("monserv_disk_reads_completed_total", "counter", "Reads completed on the device.", ioReads...)
+	p.family("monserv_disk_writes_completed_total", "counter", "Writes completed on the device.", ioWrites...)
+	p.family("monserv_disk_io_time_seconds_total", "counter", "Seconds the device spent doing I/O.", ioTime...)
+
+	// Network
+	var rxBytes, txBytes, rxPkts, txPkts, errs, drops []promSample
+	for _, n := range sm.Network {
+		rxBytes = append(rxBytes, sample(float64(n.BytesRecv), "interface", n.Name))
+		txBytes = append(txBytes, sample(float64(n.BytesSent), "interface", n.Name))
+		rxPkts = append(rxPkts, sample(float64(n.PacketsRecv), "interface", n.Name))
+		txPkts = append(txPkts, sample(float64(n.PacketsSent), "interface", n.Name))
+		errs = append(errs, sample(float64(n.ErrIn+n.ErrOut), "interface", n.Name))
+		drops = append(drops, sample(float64(n.DropIn+n.DropOut), "interface", n.Name))
+	}
+	p.family("monserv_network_receive_bytes_total", "counter", "Bytes received on the interface.", rxBytes...)
+	p.family("monserv_network_transmit_bytes_total", "counter", "Bytes sent on the interface.", txBytes...)
+	p.family("monserv_network_receive_packets_total", "counter", "Packets received on the interface.", rxPkts...)
+	p.family("monserv_network_transmit_packets_total", "counter", "Packets sent on the interface.", txPkts...)
+	p.family("monserv_network_errors_total", "counter", "Receive and transmit errors on the interface.", errs...)
+	p.family("monserv_network_drops_total", "counter", "Receive and transmit drops on the interface.", drops...)
+
+	// Sensors; names are reported as read, so repeated ones are suffixed on a copy
+	sensors := slices.Clone(sm.Sensors)
+	DedupeSensorNames(sensors)
+	temps := make([]promSample, len(sensors))
+	for i, sn := range sensors {
+		temps[i] = sample(sn.TemperatureC, "sensor", sn.Name)
+	}
+	p.family("monserv_temperature_celsius", "gauge", "Sensor temperature in degrees Celsius.", temps...)
+
+	// Containers
+	var ctUp, ctCPU, ctMem, ctLimit, ctRestarts, ctRead, ctWrite []promSample
+	for _, ct := range sm.Containers {
+		l := []string{"name", ct.Name, "image", ct.Image}
+		ctUp = append(ctUp, sample(boolValue(ct.State == "running"), l...))
+		ctCPU = append(ctCPU, sample(float64(ct.CPUUsageNs)/1e9, l...))
+		ctMem = append(ctMem, sample(float64(ct.MemoryUsage), l...))
+		if ct.MemoryLimit > 0 {
+			ctLimit = append(ctLimit, sample(float64(ct.MemoryLimit), l...))
+		}
+		ctRestarts = append(ctRestarts, sample(float64(ct.RestartCount), l...))
+		ctRead = append(ctRead, sample(float64(ct.IOReadBytes), l...))
+		ctWrite = append(ctWrite, sample(float64(ct.IOWriteBytes), l...))
+	}
+	p.family("monserv_container_running", "gauge", "Whether the container is running.", ctUp...)
+	p.family("monserv_container_cpu_seconds_total", "counter", "CPU time consumed by the container.", ctCPU...)
+	p.family("monserv_container_memory_usage_bytes", "gauge", "Container memory usage in bytes, excluding inactive page cache.", ctMem...)
+	p.family("monserv_container_memory_limit_bytes", "gauge", "Container memory limit in bytes.", ctLimit...)
+	p.family("monserv_container_restarts", "gauge", "Number of times the container was restarted.", ctRestarts...)
+	p.family("monserv_container_read_bytes_total", "counter", "Bytes read by the container.", ctRead...)
+	p.family("monserv_container_written_bytes_total", "counter", "Bytes written by the container.", ctWrite...)
+
+	// systemd units
+	var unitActive, unitRestarts []promSample
+	for _, u := range sm.SystemdUnits {
+		unitActive = append(unitActive, sample(boolValue(u.ActiveState == "active"), "unit", u.Name, "state", u.ActiveState, "sub_state", u.SubState))
+		unitRestarts = append(unitRestarts, sample(float64(u.Restarts), "unit", u.Name))
+	}
+	p.family("monserv_systemd_unit_active", "gauge", "Whether the watched systemd unit is active.", unitActive...)
+	p.family("monserv_systemd_unit_restarts", "gauge", "Automatic restarts of the watched systemd unit.", unitRestarts...)
+
+	// plugin checks
+	var ckStatus, ckDuration []promSample
+	for _, ck := range sm.Checks {
+		ckStatus = append(ckStatus, sample(float64(ck.ExitCode), "check", ck.Name))
+		ckDuration = append(ckDuration, sample(ck.DurationMs/1000, "check", ck.Name))
+	}
+	p.family("monserv_check_status", "gauge", "Plugin check state: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.", ckStatus...)
+	p.family("monserv_check_duration_seconds", "gauge", "Time taken by the last plugin check run.", ckDuration...)
+
+	// TCP sockets
+	var tcpStates, tcpListen, tcpRemote, tcpRemoteCW []promSample
+	for _, state := range sortedKeys(sm.TCP.States) {
+		tcpStates = append(tcpStates, sample(float64(sm.TCP.States[state]), "state", state))
+	}
+	for _, l := range sm.TCP.Listening {
+		tcpListen = append(tcpListen, sample(1, "address", l.Address, "port", strconv.Itoa(int(l.Port)), "process", l.Process))
+	}
+	for _, r := range sm.TCP.TopRemotes {
+		tcpRemote = append(tcpRemote, sample(float64(r.Count), "remote", r.Endpoint()))
+		tcpRemoteCW = append(tcpRemoteCW, sample(float64(r.CloseWait), "remote", r.Endpoint()))
+	}
+	p.family("monserv_tcp_connections", "gauge", "TCP sockets per state.", tcpStates...)
+	p.family("monserv_tcp_listen", "gauge", "Listening TCP sockets, always 1.", tcpListen...)
+	p.family("monserv_tcp_remote_connections", "gauge", "TCP connections to the busiest remote endpoints.", tcpRemote...)
+	p.family("monserv_tcp_remote_close_wait", "gauge", "TCP connections in CLOSE_WAIT to the busiest remote endpoints.", tcpRemoteCW...)
+
+	// pressure stall information, omitted on kernels without PSI
+	var psiAvg, psiTotal []promSample
+	for _, resource := range []string{"cpu", "memory", "io"} {
+		r := sm.Pressure.Resource(resource)
+		if r == nil {
+			continue
+		}
+		lines := []struct {
+			kind string
+			avg  *PressureAvg
+		}{{"some", &r.Some}, {"full", r.Full}}
+		for _, l := range lines {
+			if l.avg == nil {
+				continue
+			}
+			psiAvg = append(psiAvg,
+				sample(l.avg.Avg10, "resource", resource, "kind", l.kind, "window", "10s"),
+				sample(l.avg.Avg60, "resource", resource, "kind", l.kind, "window", "60s"),
+				sample(l.avg.Avg300, "resource", resource, "kind", l.kind, "window", "300s"))
+			psiTotal = append(psiTotal, sample(float64(l.avg.TotalUs)/1e6, "resource", resource, "kind", l.kind))
+		}
+	}
+	p.family("monserv_pressure_percent", "gauge", "Share of time tasks were stalled on a resource, averaged over the window.", psiAvg...)
+	p.family("monserv_pressure_stalled_seconds_total", "counter", "Total time tasks were stalled on a resource.", psiTotal...)
+
+	// process watches
+	var pwCount, pwMin, pwUptime []promSample
+	for _, w := range sm.ProcessWatches {
+		pwCount = append(pwCount, sample(float64(w.Count), "watch", w.Name))
+		pwMin = append(pwMin, sample(float64(w.MinCount), "watch", w.Name))
+		if w.Count > 0 {
+			pwUptime = append(pwUptime, sample(w.UptimeSeconds, "watch", w.Name))
+		}
+	}
+	p.family("monserv_process_watch_count", "gauge", "Processes matching the process watch.", pwCount...)
+	p.family("monserv_process_watch_min_count", "gauge", "Processes the process watch requires.", pwMin...)
+	p.family("monserv_process_watch_uptime_seconds", "gauge", "Uptime of the youngest process matching the process watch.", pwUptime...)
+
+	// log watches
+	var logMatches, logTotal []promSample
+	for _, lw := range sm.LogWatches {
+		logMatches = append(logMatches, sample(float64(lw.Matches), "watch", lw.Name))
+		logTotal = append(logTotal, sample(float64(lw.TotalMatches), "watch", lw.Name))
+	}
+	p.family("monserv_log_window_matches", "gauge", "Lines matching the log watch pattern within its window.", logMatches...)
+	p.family("monserv_log_matches_total", "counter", "Lines matching the log watch pattern since the agent started.", logTotal...)
+
+	// Top processes; only the top N are exported, so series come and go
+	var procRSS, procRAM, procCPU []promSample
+	for _, pr := range sm.TopProcsByMem {
+		l := []string{"pid", strconv.Itoa(int(pr.PID)), "name", pr.Name, "user", pr.Username}
+		procRSS = append(procRSS, sample(float64(pr.RSSBytes), l...))
+		procRAM = append(procRAM, sample(float32Value(pr.PercentRAM), l...))
+	}
+	for _, pr := range sm.TopProcsByCPU {
+		procCPU = append(procCPU, sample(pr.CPUPercent, "pid", strconv.Itoa(int(pr.PID)), "name", pr.Name, "user", pr.Username))
+	}
+	p.family("monserv_process_resident_memory_bytes", "gauge", "Resident memory of the top processes by memory.", procRSS...)
+	p.family("monserv_process_memory_percent", "gauge", "RAM percent of the top processes by memory.", procRAM...)
+	p.family("monserv_process_cpu_percent", "gauge", "CPU percent of one core used by the top processes by CPU.", procCPU...)
+
+	return bw.Flush()
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenMetrics is a sample touching every family of WritePrometheus
+func goldenMetrics() *ServerMetrics {
+	return &ServerMetrics{
+		Hostname:      "web-1",
+		UptimeSeconds: 86400,
+		Host: HostInfo{
+			OS: "linux", Platform: "ubuntu", PlatformFamily: "debian", PlatformVersion: "22.04",
+			KernelVersion: "5.15.0-91-generic", Arch: "x86_64",
+			VirtualizationSystem: "kvm", VirtualizationRole: "guest",
+			BootTimeUTC:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			AgentVersion: "1.4.0",
+		},
+		CPU: CPU{
+			Cores: 2, UsedPercent: 37.5, ModelName: "Intel(R) Xeon(R) CPU E5-2680 v4 @ 2.40GHz",
+			PerCore: []float64{50, 25},
+			Load1:   0.52, Load5: 0.58, Load15: 0.59,
+			UserPercent: 25, SystemPercent: 10, IdlePercent: 60, IOWaitPercent: 2.5, StealPercent: 2.5,
+		},
+		Memory: Memory{
+			Total: 4096000000, Used: 1024000000, Free: 3072000000, UsedPercent: 25,
+			Buffers: 102400000, Cached: 2048000000, Shared: 10240000,
+			SwapTotal: 2048000000, SwapUsed: 512000000, SwapFree: 1536000000, SwapUsedPercent: 25,
+			SwapIn: 450560, SwapOut: 1228800, OOMKills: 1,
+		},
+		Disks: []DiskPartition{
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Total: 42140401664, Used: 9144602624, Free: 30831648768, UsedPercent: 23,
+				InodesTotal: 2621440, InodesUsed: 262144, InodesFree: 2359296, InodesUsedPercent: 10},
+			// stacked mount listing the same filesystem again
+			{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Total: 42140401664, Used: 9144602624, Free: 30831648768, UsedPercent: 23},
+			{Device: "/dev/sdb1", Mountpoint: `/mnt/my "data"`, Fstype: "xfs", Total: 104857600000, Used: 52428800000, Free: 52428800000, UsedPercent: 50},
+		},
+		DiskIO: []DiskIO{
+			{Device: "sda", ReadCount: 1100, WriteCount: 2300, ReadBytes: 12288000, WriteBytes: 26624000, IoTimeMs: 2500},
+		},
+		Network: []NetInterface{
+			{Name: "eth0", BytesRecv: 1002000, BytesSent: 501000, PacketsRecv: 1020, PacketsSent: 810, ErrIn: 4, DropOut: 2},
+		},
+		Sensors: []Sensor{
+			{Name: "coretemp_package_id_0", TemperatureC: 45, HighC: 80, CriticalC: 100},
+			{Name: "acpitz", TemperatureC: 27.8},
+			{Name: "acpitz", TemperatureC: 29.8},
+		},
+		Containers: []Container{
+			{ID: "4f1c", Name: "web", Image: "nginx:1.25", State: "running", RestartCount: 2,
+				CPUUsageNs: 12500000000, MemoryUsage: 52428800, MemoryLimit: 268435456, IOReadBytes: 4096, IOWriteBytes: 8192},
+			{ID: "9a2e", Name: "migrate", Image: "app:latest", State: "exited"},
+		},
+		SystemdUnits: []SystemdUnit{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 3},
+		},
+		Checks: []CheckResult{
+			{Name: "certificate", Status: CheckWarning, ExitCode: 1, Output: "expires in 10 days", DurationMs: 250},
+		},
+		ProcessWatches: []ProcessWatch{
+			{Name: "postgres", MinCount: 1, Count: 5, PIDs: []int32{900}, UptimeSeconds: 3600},
+			{Name: "worker", MinCount: 2, Count: 0},
+		},
+		LogWatches: []LogWatch{
+			{Name: "oom", Pattern: "Out of memory", WindowSeconds: 300, Matches: 2, TotalMatches: 7},
+		},
+		TCP: TCPStats{
+			States:     map[string]int{"LISTEN": 2, "ESTABLISHED": 10, "CLOSE_WAIT": 1},
+			Listening:  []ListenSocket{{Address: "0.0.0.0", Port: 22, PID: 700, Process: "sshd"}, {Address: "::", Port: 443, PID: 812, Process: "nginx"}},
+			TopRemotes: []RemoteEndpoint{{Address: "10.0.0.5", Port: 5432, Count: 8, CloseWait: 1}},
+		},
+		Pressure: &Pressure{
+			CPU: &PressureResource{Some: PressureAvg{Avg10: 1.5, Avg60: 1, Avg300: 0.5, TotalUs: 123456}},
+			IO: &PressureResource{
+				Some: PressureAvg{Avg10: 2, Avg60: 1, Avg300: 0.25, TotalUs: 654321},
+				Full: &PressureAvg{Avg10: 1, Avg60: 0.5, Avg300: 0.1, TotalUs: 321},
+			},
+		},
+		TopProcsByMem: []ProcMem{
+			{PID: 900, Name: "postgres", Username: "postgres", RSSBytes: 204800000, PercentRAM: 5},
+			{PID: 812, Name: "node (worker)", Username: "app", RSSBytes: 102400000, PercentRAM: 2.5},
+		},
+		TopProcsByCPU: []ProcCPU{
+			{PID: 812, Name: "node (worker)", Username: "app", CPUPercent: 100},
+		},
+		GeneratedAtUTC:       time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		CollectionDurationMs: 1250,
+		SampleAgeSeconds:     3,
+		CollectionErrors:     map[string]string{"tcp": "exit status 1", "disk": "no output"},
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	const golden = "testdata/metrics.prom"
+	var buf bytes.Buffer
+	sm := goldenMetrics()
+	if err := sm.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if sm.Sensors[2].Name != "acpitz" {
+		t.Errorf("WritePrometheus renamed the sensors of the sample to %q", sm.Sensors[2].Name)
+	}
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("WritePrometheus() output differs from %s; rerun with -update after checking the change\ngot:\n%s", golden, buf.Bytes())
+	}
+}
+
+func TestWritePrometheusEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&ServerMetrics{}).WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// families without samples are left out, so none of the per-item families appear
+	for _, name := range []string{"monserv_filesystem_size_bytes", "monserv_temperature_celsius", "monserv_container_running", "monserv_pressure_percent", "monserv_boot_time_seconds"} {
+		if bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("empty metrics expose %s", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import "time"
+
+// Rate returns the per-second increase of a cumulative counter between two samples.
+// Counter resets (cur < prev) and non-positive intervals yield 0.
+func Rate(cur, prev uint64, elapsed time.Duration) float64 {
+	if elapsed <= 0 || cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / elapsed.Seconds()
+}
+
+// ComputeRates fills the PerSec fields from a previous sample of the same interface
+func (n *NetInterface) ComputeRates(prev NetInterface, elapsed time.Duration) {
+	n.BytesRecvPerSec = Rate(n.BytesRecv, prev.BytesRecv, elapsed)
+	n.BytesSentPerSec = Rate(n.BytesSent, prev.BytesSent, elapsed)
+	n.PacketsRecvPerSec = Rate(n.PacketsRecv, prev.PacketsRecv, elapsed)
+	n.PacketsSentPerSec = Rate(n.PacketsSent, prev.PacketsSent, elapsed)
+	n.ErrorsPerSec = Rate(n.ErrIn+n.ErrOut, prev.ErrIn+prev.ErrOut, elapsed)
+	n.DropsPerSec = Rate(n.DropIn+n.DropOut, prev.DropIn+prev.DropOut, elapsed)
+}
+
+// ComputeSwapRates fills the swap in/out rates from a previous sample
+func (mem *Memory) ComputeSwapRates(prev Memory, elapsed time.Duration) {
+	mem.SwapInPerSec = Rate(mem.SwapIn, prev.SwapIn, elapsed)
+	mem.SwapOutPerSec = Rate(mem.SwapOut, prev.SwapOut, elapsed)
+}
+
+// ComputeRates fills throughput, IOPS, await and utilisation from a previous sample of the same device
+func (d *DiskIO) ComputeRates(prev DiskIO, elapsed time.Duration) {
+	if elapsed <= 0 {
+		return
+	}
+	d.ReadBytesPerSec = Rate(d.ReadBytes, prev.ReadBytes, elapsed)
+	d.WriteBytesPerSec = Rate(d.WriteBytes, prev.WriteBytes, elapsed)
+	d.ReadOpsPerSec = Rate(d.ReadCount, prev.ReadCount, elapsed)
+	d.WriteOpsPerSec = Rate(d.WriteCount, prev.WriteCount, elapsed)
+
+	ops := d.ReadCount + d.WriteCount
+	prevOps := prev.ReadCount + prev.WriteCount
+	waited := d.ReadTimeMs + d.WriteTimeMs
+	prevWaited := prev.ReadTimeMs + prev.WriteTimeMs
+	if ops > prevOps && waited >= prevWaited {
+		d.AwaitMs = float64(waited-prevWaited) / float64(ops-prevOps)
+	}
+	if d.IoTimeMs >= prev.IoTimeMs {
+		d.UtilPercent = min(float64(d.IoTimeMs-prev.IoTimeMs)/(elapsed.Seconds()*1000)*100, 100)
+	}
+}
+
+// ComputeRates fills CPU percent and I/O throughput from a previous sample of the same container
+func (ct *Container) ComputeRates(prev Container, elapsed time.Duration) {
+	if elapsed > 0 && ct.CPUUsageNs >= prev.CPUUsageNs {
+		ct.CPUPercent = float64(ct.CPUUsageNs-prev.CPUUsageNs) / float64(elapsed.Nanoseconds()) * 100
+	}
+	ct.IOReadBytesPerSec = Rate(ct.IOReadBytes, prev.IOReadBytes, elapsed)
+	ct.IOWriteBytesPerSec = Rate(ct.IOWriteBytes, prev.IOWriteBytes, elapsed)
+}
+
+func (t CPUTimes) total() float64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+// BusyPercent returns the share of non-idle, non-iowait time between two samples
+func BusyPercent(cur, prev CPUTimes) float64 {
+	total := cur.total() - prev.total()
+	if total <= 0 {
+		return 0
+	}
+	idle := (cur.Idle - prev.Idle) + (cur.IOWait - prev.IOWait)
+	return clampPercent((total - idle) / total * 100)
+}
+
+// SetTimes fills UsedPercent and the per-state percentages from two samples of the aggregate CPU times
+func (c *CPU) SetTimes(cur, prev CPUTimes) {
+	total := cur.total() - prev.total()
+	if total <= 0 {
+		return
+	}
+	pct := func(a, b float64) float64 { return clampPercent((a - b) / total * 100) }
+	c.UsedPercent = BusyPercent(cur, prev)
+	c.UserPercent = pct(cur.User, prev.User)
+	c.NicePercent = pct(cur.Nice, prev.Nice)
+	c.SystemPercent = pct(cur.System, prev.System)
+	c.IdlePercent = pct(cur.Idle, prev.Idle)
+	c.IOWaitPercent = pct(cur.IOWait, prev.IOWait)
+	c.IRQPercent = pct(cur.IRQ, prev.IRQ)
+	c.SoftIRQPercent = pct(cur.SoftIRQ, prev.SoftIRQ)
+	c.StealPercent = pct(cur.Steal, prev.Steal)
+}
+
+func clampPercent(v float64) float64 {
+	return max(0, min(v, 100))
+}
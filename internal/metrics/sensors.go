@@ -0,0 +1,16 @@
+package metrics
+
+import "strconv"
+
+// DedupeSensorNames suffixes repeated sensor names with _2, _3, ... so every
+// sensor can be addressed individually (e.g. several unlabeled acpitz inputs)
+func DedupeSensorNames(sensors []Sensor) {
+	seen := map[string]int{}
+	for i := range sensors {
+		name := sensors[i].Name
+		seen[name]++
+		if n := seen[name]; n > 1 {
+			sensors[i].Name = name + "_" + strconv.Itoa(n)
+		}
+	}
+}
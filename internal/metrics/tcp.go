@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strconv"
+)
+
+// MaxTCPRemotes caps the remote endpoints reported per host
+const MaxTCPRemotes = 20
+
+// TCPConn is one TCP socket as read from gopsutil or /proc/net/tcp{,6}
+type TCPConn struct {
+	State      string
+	LocalAddr  string
+	LocalPort  uint32
+	RemoteAddr string
+	RemotePort uint32
+	PID        int32 // 0 when the owner is unknown
+}
+
+// Endpoint formats the remote address as host:port, bracketing IPv6 addresses
+func (r RemoteEndpoint) Endpoint() string {
+	return net.JoinHostPort(r.Address, strconv.FormatUint(uint64(r.Port), 10))
+}
+
+// NewTCPStats counts the sockets per state, lists the listening sockets and keeps the
+// remote endpoints with the most connections. Process names of listeners are left to the caller.
+func NewTCPStats(conns []TCPConn) TCPStats {
+	st := TCPStats{States: map[string]int{}, Listening: []ListenSocket{}, TopRemotes: []RemoteEndpoint{}}
+	type endpoint struct {
+		addr string
+		port uint32
+	}
+	listening := map[endpoint]int{}
+	remotes := map[endpoint]*RemoteEndpoint{}
+	for _, c := range conns {
+		st.States[c.State]++
+		if c.State == "LISTEN" {
+			ep := endpoint{c.LocalAddr, c.LocalPort}
+			// SO_REUSEPORT listeners share an endpoint; keep one, preferring a known owner
+			if i, ok := listening[ep]; ok {
+				if st.Listening[i].PID == 0 {
+					st.Listening[i].PID = c.PID
+				}
+				continue
+			}
+			listening[ep] = len(st.Listening)
+			st.Listening = append(st.Listening, ListenSocket{Address: c.LocalAddr, Port: c.LocalPort, PID: c.PID})
+			continue
+		}
+		if c.RemoteAddr == "" || c.RemotePort == 0 {
+			continue
+		}
+		ep := endpoint{c.RemoteAddr, c.RemotePort}
+		r, ok := remotes[ep]
+		if !ok {
+			r = &RemoteEndpoint{Address: c.RemoteAddr, Port: c.RemotePort}
+			remotes[ep] = r
+		}
+		r.Count++
+		if c.State == "CLOSE_WAIT" {
+			r.CloseWait++
+		}
+	}
+	sort.Slice(st.Listening, func(i, j int) bool {
+		if st.Listening[i].Port != st.Listening[j].Port {
+			return st.Listening[i].Port < st.Listening[j].Port
+		}
+		return st.Listening[i].Address < st.Listening[j].Address
+	})
+	for _, r := range remotes {
+		st.TopRemotes = append(st.TopRemotes, *r)
+	}
+	sort.Slice(st.TopRemotes, func(i, j int) bool {
+		a, b := st.TopRemotes[i], st.TopRemotes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Endpoint() < b.Endpoint()
+	})
+	if len(st.TopRemotes) > MaxTCPRemotes {
+		st.TopRemotes = st.TopRemotes[:MaxTCPRemotes]
+	}
+	return st
+}
@@ -2,30 +2,89 @@ package metrics
 
 import "time"
 
-// CPU holds processor usage details
+// CPU holds processor usage details.
+// The state percentages (user, system, iowait, ...) split the sampling
+// window of all cores together and add up to roughly 100.
 type CPU struct {
-	Cores       int     `json:"cores"`
-	UsedPercent float64 `json:"usedPercent"`
-	ModelName   string  `json:"modelName"`
+	Cores          int       `json:"cores"`
+	UsedPercent    float64   `json:"usedPercent"`
+	ModelName      string    `json:"modelName"`
+	PerCore        []float64 `json:"perCore"`
+	Load1          float64   `json:"load1"`
+	Load5          float64   `json:"load5"`
+	Load15         float64   `json:"load15"`
+	UserPercent    float64   `json:"userPercent"`
+	NicePercent    float64   `json:"nicePercent"`
+	SystemPercent  float64   `json:"systemPercent"`
+	IdlePercent    float64   `json:"idlePercent"`
+	IOWaitPercent  float64   `json:"iowaitPercent"`
+	IRQPercent     float64   `json:"irqPercent"`
+	SoftIRQPercent float64   `json:"softirqPercent"`
+	StealPercent   float64   `json:"stealPercent"`
 }
 
-// Memory holds RAM usage details
+// LoadPerCore returns the 5 minute load average divided by the number of cores
+func (c CPU) LoadPerCore() float64 {
+	if c.Cores <= 0 {
+		return c.Load5
+	}
+	return c.Load5 / float64(c.Cores)
+}
+
+// CPUTimes holds cumulative time spent in each CPU state, as found in /proc/stat.
+// Units do not matter (seconds or jiffies) as long as both samples use the same.
+type CPUTimes struct {
+	User    float64
+	Nice    float64
+	System  float64
+	Idle    float64
+	IOWait  float64
+	IRQ     float64
+	SoftIRQ float64
+	Steal   float64
+}
+
+// Memory holds RAM and swap usage details.
+// Free is the memory available to new applications, so page cache is not counted as used.
+// SwapIn/SwapOut are cumulative bytes; their PerSec rates stay zero on the first sample.
 type Memory struct {
-	Total       uint64  `json:"total"`
-	Used        uint64  `json:"used"`
-	Free        uint64  `json:"free"`
-	UsedPercent float64 `json:"usedPercent"`
+	Total           uint64  `json:"total"`
+	Used            uint64  `json:"used"`
+	Free            uint64  `json:"free"`
+	UsedPercent     float64 `json:"usedPercent"`
+	Buffers         uint64  `json:"buffers"`
+	Cached          uint64  `json:"cached"`
+	Shared          uint64  `json:"shared"`
+	HugePagesTotal  uint64  `json:"hugePagesTotal"`
+	HugePagesFree   uint64  `json:"hugePagesFree"`
+	HugePageSize    uint64  `json:"hugePageSize"`
+	SwapTotal       uint64  `json:"swapTotal"`
+	SwapUsed        uint64  `json:"swapUsed"`
+	SwapFree        uint64  `json:"swapFree"`
+	SwapUsedPercent float64 `json:"swapUsedPercent"`
+	SwapIn          uint64  `json:"swapIn"`
+	SwapOut         uint64  `json:"swapOut"`
+	SwapInPerSec    float64 `json:"swapInPerSec"`
+	SwapOutPerSec   float64 `json:"swapOutPerSec"`
+	// OOMKills is the number of processes killed by the kernel OOM killer since boot
+	// (oom_kill in /proc/vmstat, kernels 4.13 and later)
+	OOMKills uint64 `json:"oomKills"`
 }
 
-// DiskPartition describes a single mount point usage
+// DiskPartition describes a single mount point usage.
+// Inode fields stay zero on filesystems without a fixed inode table (btrfs, vfat, ...).
 type DiskPartition struct {
-	Device      string  `json:"device"`
-	Mountpoint  string  `json:"mountpoint"`
-	Fstype      string  `json:"fstype"`
-	Total       uint64  `json:"total"`
-	Used        uint64  `json:"used"`
-	Free        uint64  `json:"free"`
-	UsedPercent float64 `json:"usedPercent"`
+	Device            string  `json:"device"`
+	Mountpoint        string  `json:"mountpoint"`
+	Fstype            string  `json:"fstype"`
+	Total             uint64  `json:"total"`
+	Used              uint64  `json:"used"`
+	Free              uint64  `json:"free"`
+	UsedPercent       float64 `json:"usedPercent"`
+	InodesTotal       uint64  `json:"inodesTotal"`
+	InodesUsed        uint64  `json:"inodesUsed"`
+	InodesFree        uint64  `json:"inodesFree"`
+	InodesUsedPercent float64 `json:"inodesUsedPercent"`
 }
 
 // ProcMem describes a process memory usage summary
@@ -38,13 +97,274 @@ type ProcMem struct {
 	Cmdline    string  `json:"cmdline"`
 }
 
+// ProcCPU describes a process CPU usage summary.
+// CPUPercent is measured over the sampling window and is relative to a
+// single core, so a multi-threaded process can exceed 100.
+type ProcCPU struct {
+	PID        int32   `json:"pid"`
+	Name       string  `json:"name"`
+	Username   string  `json:"username"`
+	CPUPercent float64 `json:"cpuPercent"`
+	RSSBytes   uint64  `json:"rssBytes"`
+	Cmdline    string  `json:"cmdline"`
+}
+
+// Pressure holds the pressure stall information (PSI) of a host. A resource is nil when
+// the kernel does not report it; CPU "full" is only reported by kernels 5.13 and later.
+type Pressure struct {
+	CPU    *PressureResource `json:"cpu,omitempty"`
+	Memory *PressureResource `json:"memory,omitempty"`
+	IO     *PressureResource `json:"io,omitempty"`
+}
+
+// PressureResource holds the share of time some or all non-idle tasks were stalled on a resource
+type PressureResource struct {
+	Some PressureAvg  `json:"some"`
+	Full *PressureAvg `json:"full,omitempty"`
+}
+
+// PressureAvg holds stall percentages averaged over 10, 60 and 300 seconds and the
+// cumulative stall time in microseconds
+type PressureAvg struct {
+	Avg10   float64 `json:"avg10"`
+	Avg60   float64 `json:"avg60"`
+	Avg300  float64 `json:"avg300"`
+	TotalUs uint64  `json:"totalUs"`
+}
+
+// TCPStats summarises the TCP sockets (IPv4 and IPv6) of a host
+type TCPStats struct {
+	// States counts sockets per state, e.g. ESTABLISHED, TIME_WAIT, CLOSE_WAIT, LISTEN
+	States    map[string]int `json:"states"`
+	Listening []ListenSocket `json:"listening"`
+	// TopRemotes are the remote endpoints with the most connections, LISTEN excluded
+	TopRemotes []RemoteEndpoint `json:"topRemotes"`
+}
+
+// ListenSocket is a listening TCP socket and, when it can be resolved, its owner
+type ListenSocket struct {
+	Address string `json:"address"`
+	Port    uint32 `json:"port"`
+	PID     int32  `json:"pid"`
+	Process string `json:"process"`
+}
+
+// RemoteEndpoint counts the connections to one remote address and port
+type RemoteEndpoint struct {
+	Address   string `json:"address"`
+	Port      uint32 `json:"port"`
+	Count     int    `json:"count"`
+	CloseWait int    `json:"closeWait"`
+}
+
+// ProcessWatch reports the processes selected by one process matcher
+type ProcessWatch struct {
+	Name     string  `json:"name"`
+	MinCount int     `json:"minCount"`
+	Count    int     `json:"count"`
+	PIDs     []int32 `json:"pids"` // sorted, at most 64
+	// UptimeSeconds is the uptime of the youngest matching process
+	UptimeSeconds float64 `json:"uptimeSeconds"`
+}
+
+// NetInterface describes traffic counters of a single network interface.
+// Counters are cumulative since boot; the PerSec fields are computed from
+// the previous sample and stay zero on the first one.
+type NetInterface struct {
+	Name              string  `json:"name"`
+	BytesRecv         uint64  `json:"bytesRecv"`
+	BytesSent         uint64  `json:"bytesSent"`
+	PacketsRecv       uint64  `json:"packetsRecv"`
+	PacketsSent       uint64  `json:"packetsSent"`
+	ErrIn             uint64  `json:"errIn"`
+	ErrOut            uint64  `json:"errOut"`
+	DropIn            uint64  `json:"dropIn"`
+	DropOut           uint64  `json:"dropOut"`
+	BytesRecvPerSec   float64 `json:"bytesRecvPerSec"`
+	BytesSentPerSec   float64 `json:"bytesSentPerSec"`
+	PacketsRecvPerSec float64 `json:"packetsRecvPerSec"`
+	PacketsSentPerSec float64 `json:"packetsSentPerSec"`
+	ErrorsPerSec      float64 `json:"errorsPerSec"`
+	DropsPerSec       float64 `json:"dropsPerSec"`
+}
+
+// DiskIO describes I/O activity of a single block device.
+// Counters are cumulative since boot; rates, await and utilisation are
+// computed from the previous sample and stay zero on the first one.
+type DiskIO struct {
+	Device           string  `json:"device"`
+	ReadCount        uint64  `json:"readCount"`
+	WriteCount       uint64  `json:"writeCount"`
+	ReadBytes        uint64  `json:"readBytes"`
+	WriteBytes       uint64  `json:"writeBytes"`
+	ReadTimeMs       uint64  `json:"readTimeMs"`
+	WriteTimeMs      uint64  `json:"writeTimeMs"`
+	IoTimeMs         uint64  `json:"ioTimeMs"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSec"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSec"`
+	ReadOpsPerSec    float64 `json:"readOpsPerSec"`
+	WriteOpsPerSec   float64 `json:"writeOpsPerSec"`
+	AwaitMs          float64 `json:"awaitMs"`
+	UtilPercent      float64 `json:"utilPercent"`
+}
+
+// Sensor describes a hardware temperature reading in degrees Celsius.
+// HighC and CriticalC are the limits reported by the hardware, 0 when unknown.
+type Sensor struct {
+	Name         string  `json:"name"`
+	TemperatureC float64 `json:"temperatureC"`
+	HighC        float64 `json:"highC"`
+	CriticalC    float64 `json:"criticalC"`
+}
+
+// Container is the resource usage of one container read from its cgroup,
+// enriched with Docker Engine API details when available
+type Container struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	State        string `json:"state"`
+	RestartCount int    `json:"restartCount"`
+	// cumulative counters
+	CPUUsageNs   uint64 `json:"cpuUsageNs"`
+	IOReadBytes  uint64 `json:"ioReadBytes"`
+	IOWriteBytes uint64 `json:"ioWriteBytes"`
+	// CPUPercent is relative to one core, like process CPU
+	CPUPercent         float64 `json:"cpuPercent"`
+	MemoryUsage        uint64  `json:"memoryUsage"`
+	MemoryLimit        uint64  `json:"memoryLimit"` // 0 when unlimited
+	MemoryPercent      float64 `json:"memoryPercent"`
+	IOReadBytesPerSec  float64 `json:"ioReadBytesPerSec"`
+	IOWriteBytesPerSec float64 `json:"ioWriteBytesPerSec"`
+}
+
+// SystemdUnit is the state of a watched systemd unit
+type SystemdUnit struct {
+	Name        string `json:"name"`
+	LoadState   string `json:"loadState"`   // loaded, not-found, masked...
+	ActiveState string `json:"activeState"` // active, inactive, failed, activating...
+	SubState    string `json:"subState"`    // running, exited, dead...
+	Restarts    int    `json:"restarts"`    // automatic restarts by systemd (NRestarts)
+}
+
+// Failed reports whether the unit failed or is unknown to systemd. Inactive units are not
+// failed: oneshot and timer-activated services are inactive between runs, and units can
+// be stopped on purpose. Transitional states such as activating are not considered either.
+func (u SystemdUnit) Failed() bool {
+	return u.ActiveState == "failed" || u.LoadState == "not-found"
+}
+
+// Check states, following Nagios plugin exit codes 0-3
+const (
+	CheckOK       = "OK"
+	CheckWarning  = "WARNING"
+	CheckCritical = "CRITICAL"
+	CheckUnknown  = "UNKNOWN"
+)
+
+// CheckResult is the latest result of an agent plugin check
+type CheckResult struct {
+	Name         string     `json:"name"`
+	Status       string     `json:"status"` // OK, WARNING, CRITICAL or UNKNOWN
+	ExitCode     int        `json:"exitCode"`
+	Output       string     `json:"output"`
+	Perfdata     []PerfData `json:"perfdata"`
+	DurationMs   float64    `json:"durationMs"`
+	CheckedAtUTC time.Time  `json:"checkedAtUtc"`
+}
+
+// PerfData is one Nagios performance data item: 'label'=value[UOM];[warn];[crit];[min];[max].
+// Warn and Crit are kept as Nagios range strings such as "10:20" or "@5".
+type PerfData struct {
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	UOM   string   `json:"uom,omitempty"`
+	Warn  string   `json:"warn,omitempty"`
+	Crit  string   `json:"crit,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// LogWatch reports the regex matches of one agent log watch
+type LogWatch struct {
+	Name          string   `json:"name"`
+	Pattern       string   `json:"pattern"`
+	Files         []string `json:"files"` // files currently tailed
+	WindowSeconds int      `json:"windowSeconds"`
+	// Matches counts matching lines in the last WindowSeconds
+	Matches int `json:"matches"`
+	// Threshold is the match count per window above which the server alerts; 0 disables
+	Threshold    int       `json:"threshold"`
+	TotalMatches uint64    `json:"totalMatches"` // since the agent started
+	LastLines    []LogLine `json:"lastLines"`    // most recent matching lines, oldest first
+	Error        string    `json:"error,omitempty"`
+}
+
+// LogLine is one matching log line
+type LogLine struct {
+	File      string    `json:"file"`
+	Text      string    `json:"text"`
+	SeenAtUTC time.Time `json:"seenAtUtc"`
+}
+
+// HostInfo is the inventory of a host. Fields the collector could not determine stay
+// empty; AgentVersion is empty for hosts collected over SSH.
+type HostInfo struct {
+	OS                   string    `json:"os"`
+	Platform             string    `json:"platform"`
+	PlatformFamily       string    `json:"platformFamily"`
+	PlatformVersion      string    `json:"platformVersion"`
+	KernelVersion        string    `json:"kernelVersion"`
+	Arch                 string    `json:"arch"`
+	VirtualizationSystem string    `json:"virtualizationSystem"`
+	VirtualizationRole   string    `json:"virtualizationRole"`
+	BootTimeUTC          time.Time `json:"bootTimeUtc"`
+	AgentVersion         string    `json:"agentVersion"`
+}
+
+// Event types
+const (
+	EventOOMKill = "oom_kill"
+	EventReboot  = "reboot"
+)
+
+// Event is a one-shot occurrence on a host, such as an OOM kill or a reboot.
+// Unlike alerts, events have no recovery.
+type Event struct {
+	ServerURL  string    `json:"serverUrl"`
+	Hostname   string    `json:"hostname"`
+	Type       string    `json:"type"`
+	Subject    string    `json:"subject"`
+	Message    string    `json:"message"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
+
 // ServerMetrics is the full payload exposed by agents
 type ServerMetrics struct {
 	Hostname       string          `json:"hostname"`
 	UptimeSeconds  uint64          `json:"uptimeSeconds"`
+	Host           HostInfo        `json:"host"`
 	CPU            CPU             `json:"cpu"`
 	Memory         Memory          `json:"memory"`
 	Disks          []DiskPartition `json:"disks"`
-	TopProcsByMem  []ProcMem       `json:"topProcsByMem"`
-	GeneratedAtUTC time.Time       `json:"generatedAtUtc"`
+	DiskIO         []DiskIO        `json:"diskIO"`
+	Network        []NetInterface  `json:"network"`
+	Sensors        []Sensor        `json:"sensors"`
+	Containers     []Container     `json:"containers"`
+	SystemdUnits   []SystemdUnit   `json:"systemdUnits"`
+	Checks         []CheckResult   `json:"checks"`
+	LogWatches     []LogWatch      `json:"logWatches"`
+	ProcessWatches []ProcessWatch  `json:"processWatches"`
+	TCP            TCPStats        `json:"tcp"`
+	// Pressure is nil on kernels without PSI
+	Pressure       *Pressure `json:"pressure,omitempty"`
+	TopProcsByMem  []ProcMem `json:"topProcsByMem"`
+	TopProcsByCPU  []ProcCPU `json:"topProcsByCpu"`
+	GeneratedAtUTC time.Time `json:"generatedAtUtc"`
+	// CollectionDurationMs is how long the sample took to collect
+	CollectionDurationMs float64 `json:"collectionDurationMs"`
+	// SampleAgeSeconds is the age of a cached sample when it was served; 0 for fresh samples
+	SampleAgeSeconds float64 `json:"sampleAgeSeconds"`
+	// CollectionErrors maps a sub-collector name (cpu, memory, disk, ...) to its error
+	CollectionErrors map[string]string `json:"collectionErrors,omitempty"`
 }
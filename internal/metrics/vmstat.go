@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+)
+
+// ParseOOMKills returns the oom_kill counter of /proc/vmstat; 0 on kernels without it
+func ParseOOMKills(vmstat string) uint64 {
+	sc := bufio.NewScanner(strings.NewReader(vmstat))
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "oom_kill "); ok {
+			n, _ := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
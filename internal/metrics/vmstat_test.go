@@ -0,0 +1,29 @@
+package metrics
+
+import "testing"
+
+func TestParseOOMKills(t *testing.T) {
+	const before = "nr_free_pages 856873\npgpgin 1008910\npgpgout 2020160\npswpin 0\npswpout 0\n" +
+		"kswapd_high_wmark_hit_quickly 0\npageoutrun 0\npgrotated 36\ndrop_pagecache 2\ndrop_slab 2\n"
+	const after = "numa_pte_updates 0\nnuma_huge_pte_updates 0\nnuma_hint_faults 0\n"
+	tests := []struct {
+		name   string
+		vmstat string
+		want   uint64
+	}{
+		{name: "no kills", vmstat: before + "oom_kill 0\n" + after, want: 0},
+		{name: "kills", vmstat: before + "oom_kill 17\n" + after, want: 17},
+		{name: "last line without newline", vmstat: before + "oom_kill 3", want: 3},
+		// the counter was added in Linux 4.13
+		{name: "older kernel", vmstat: before + after, want: 0},
+		{name: "similar names", vmstat: "oom_kill_total 9\nnr_oom_kill 5\n", want: 0},
+		{name: "empty", vmstat: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseOOMKills(tt.vmstat); got != tt.want {
+				t.Errorf("ParseOOMKills() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -144,14 +144,50 @@ func sanitizeTag(s string) string {
 func mapJenisFromSubject(subject string) string {
 	s := strings.ToUpper(subject)
 	switch {
+	// collector names such as "cpu" or "disk" must not be taken for a resource alert
+	case strings.Contains(s, " COLLECTOR "):
+		return getenvDefault("KAFKA_JENIS_COLLECTOR", "COLLECTOR_ERROR")
+	// one-shot host events
+	case strings.HasPrefix(s, "[EVENT]") && strings.Contains(s, " OOM KILL"):
+		return getenvDefault("KAFKA_JENIS_OOM_KILL", "OOM_KILL")
+	case strings.HasPrefix(s, "[EVENT]") && strings.Contains(s, " REBOOTED"):
+		return getenvDefault("KAFKA_JENIS_REBOOT", "REBOOT")
+	// spaced like the other kinds, so that containerd units and processes or hosts
+	// named container-* keep their own jenis
+	case strings.Contains(s, " CONTAINER "):
+		return getenvDefault("KAFKA_JENIS_CONTAINER", "CONTAINER")
+	case strings.Contains(s, " CHECK "):
+		return getenvDefault("KAFKA_JENIS_PLUGIN_CHECK", "PLUGIN_CHECK")
+	case strings.Contains(s, "WATCHED PROCESS"):
+		return getenvDefault("KAFKA_JENIS_PROCESS_WATCH", "PROCESS_WATCH")
+	case strings.Contains(s, " TCP "):
+		return getenvDefault("KAFKA_JENIS_TCP", "TCP_CONNECTION")
+	case strings.Contains(s, " PRESSURE "):
+		return getenvDefault("KAFKA_JENIS_PRESSURE", "PRESSURE_STALL")
+	case strings.Contains(s, " LOG "):
+		return getenvDefault("KAFKA_JENIS_LOG_PATTERN", "LOG_PATTERN")
+	case strings.Contains(s, " UNIT "):
+		return getenvDefault("KAFKA_JENIS_SYSTEMD_UNIT", "SYSTEMD_UNIT")
+	case strings.Contains(s, "PROC") && strings.Contains(s, "CPU"):
+		return getenvDefault("KAFKA_JENIS_CPU_BY_APP", "CPU_BY_APLIKASI")
 	case strings.Contains(s, "MEMORY BY") || strings.Contains(s, "PROC") || strings.Contains(s, "PROCESS"):
 		return getenvDefault("KAFKA_JENIS_MEM_BY_APP", "MEMORY_BY_APLIKASI")
+	case strings.Contains(s, "SWAP"):
+		return getenvDefault("KAFKA_JENIS_SWAP", "SWAP_USAGE")
 	case strings.Contains(s, "MEMORY"):
 		return getenvDefault("KAFKA_JENIS_MEM_SERVER", "MEMORY_SERVER")
+	case strings.Contains(s, "TEMPERATURE"):
+		return getenvDefault("KAFKA_JENIS_TEMPERATURE", "TEMPERATURE")
+	case strings.Contains(s, "INODES"):
+		return getenvDefault("KAFKA_JENIS_INODE_USAGE", "INODE_USAGE")
+	case strings.Contains(s, "DISK I/O"):
+		return getenvDefault("KAFKA_JENIS_DISK_IO", "DISK_IO")
 	case strings.Contains(s, "DISK"):
 		return getenvDefault("KAFKA_JENIS_DISK_USAGE", "DISK_USAGE")
 	case strings.Contains(s, "CPU"):
 		return getenvDefault("KAFKA_JENIS_CPU_USAGE", "CPU_USAGE")
+	case strings.Contains(s, " NET "):
+		return getenvDefault("KAFKA_JENIS_NETWORK", "NETWORK")
 	default:
 		return getenvDefault("KAFKA_JENIS_DEFAULT", "ALERT_GENERIC")
 	}
@@ -0,0 +1,47 @@
+package notifier
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMapJenisFromSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"[ALERT] http://10.0.0.5:9000 container web CPU high", "CONTAINER"},
+		{"[RECOVERED] http://10.0.0.5:9000 container web", "CONTAINER"},
+		{"[ALERT] http://10.0.0.5:9000 unit containerd.service failed", "SYSTEMD_UNIT"},
+		{"[ALERT] http://10.0.0.5:9000 proc containerd(812) RAM high", "MEMORY_BY_APLIKASI"},
+		{"[ALERT] http://10.0.0.5:9000 proc containerd(812) CPU high", "CPU_BY_APLIKASI"},
+		{"[ALERT] http://10.0.0.5:9000 watched process containerd missing", "PROCESS_WATCH"},
+		{"[ALERT] http://container-node-1:9000 CPU high", "CPU_USAGE"},
+		{"[ALERT] ssh://root@container-node-1:22 disk / high", "DISK_USAGE"},
+		{"[ALERT] http://container-node-1:9000 memory high", "MEMORY_SERVER"},
+		{"[ALERT] http://10.0.0.5:9000 disk I/O sda latency high", "DISK_IO"},
+		{"[ALERT] http://10.0.0.5:9000 check certificate CRITICAL", "PLUGIN_CHECK"},
+		{"[ALERT] http://10.0.0.5:9000 collector cpu failing", "COLLECTOR_ERROR"},
+		{"[ALERT] http://10.0.0.5:9000 TCP connections to 10.0.0.7:5432 high", "TCP_CONNECTION"},
+		{"[ALERT] http://10.0.0.5:9000 io pressure high", "PRESSURE_STALL"},
+		{"[ALERT] http://10.0.0.5:9000 log oom matches high", "LOG_PATTERN"},
+		{"[EVENT] http://10.0.0.5:9000 OOM kill", "OOM_KILL"},
+		{"[EVENT] http://container-node-1:9000 rebooted", "REBOOT"},
+		{"[ALERT] http://10.0.0.5:9000 net eth0 errors high", "NETWORK"},
+		{"[ALERT] something else", "ALERT_GENERIC"},
+	}
+	// the defaults are checked, not whatever the environment overrides
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); strings.HasPrefix(k, "KAFKA_JENIS_") {
+			t.Setenv(k, "")
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := mapJenisFromSubject(tt.subject); got != tt.want {
+				t.Errorf("mapJenisFromSubject(%q) = %q, want %q", tt.subject, got, tt.want)
+			}
+		})
+	}
+}
@@ -20,14 +20,20 @@ type MetricsRepository interface {
 	SetAlert(key string, active bool)
 	DeleteAlert(key string)
 	GetLastUpdate(serverURL string) time.Time
+	AddEvent(e m.Event)
+	GetEvents() []m.Event
 }
 
+// maxEvents bounds the event history; the oldest events are dropped first
+const maxEvents = 1000
+
 // InMemoryMetricsRepository implementasi in-memory
 type InMemoryMetricsRepository struct {
 	mu          sync.RWMutex
 	metrics     map[string]*m.ServerMetrics
 	alerts      map[string]bool
 	lastUpdates map[string]time.Time
+	events      []m.Event
 }
 
 func NewInMemoryMetricsRepository() *InMemoryMetricsRepository {
@@ -139,3 +145,21 @@ func (r *InMemoryMetricsRepository) GetLastUpdate(serverURL string) time.Time {
 	}
 	return time.Time{}
 }
+
+func (r *InMemoryMetricsRepository) AddEvent(e m.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, e)
+	if len(r.events) > maxEvents {
+		r.events = append([]m.Event(nil), r.events[len(r.events)-maxEvents:]...)
+	}
+}
+
+// GetEvents returns the event history, oldest first
+func (r *InMemoryMetricsRepository) GetEvents() []m.Event {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]m.Event(nil), r.events...)
+}
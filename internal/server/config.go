@@ -1,20 +1,80 @@
 package server
 
 import (
+	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	m "monserv/internal/metrics"
+	"monserv/internal/utils"
 )
 
 type Config struct {
-	Agents        []string
-	PollInterval  time.Duration
-	CPUThreshold  float64
-	MemThreshold  float64
-	DiskThreshold float64
-	ProcThreshold float64
-	LogThresholds bool
+	Agents       []string
+	PollInterval time.Duration
+	CPUThreshold float64
+	// LoadPerCoreThreshold applies to the 5 minute load average divided by cores; 0 disables the check
+	LoadPerCoreThreshold float64
+	IOWaitThreshold      float64 // percent of CPU time waiting for I/O; 0 disables the check
+	MemThreshold         float64
+	SwapThreshold        float64 // percent of swap used; 0 disables the check
+	DiskThreshold        float64
+	InodeThreshold       float64 // percent of inodes used per mount; 0 disables the check
+	ProcThreshold        float64
+	ProcCPUThreshold     float64 // per-process CPU percent of one core; 0 disables the check
+	// Disk I/O thresholds per block device; 0 disables the check
+	DiskIOUtilThreshold  float64 // percent of time the device was busy
+	DiskIOAwaitThreshold float64 // average milliseconds per I/O
+	// Network thresholds per interface; 0 disables the check
+	NetErrorThreshold      float64 // errors+drops per second
+	NetThroughputThreshold float64 // bytes per second in either direction
+	// TempThreshold is the default sensor temperature limit in Celsius; 0 disables the check.
+	// SensorThresholds overrides it per sensor name.
+	TempThreshold    float64
+	SensorThresholds map[string]float64
+	// TCPStateThresholds limits the socket count per TCP state, e.g. CLOSE_WAIT; states not listed are not checked
+	TCPStateThresholds map[string]float64
+	// TCPRemoteThreshold limits the connections to one remote endpoint; 0 disables the check.
+	// TCPRemoteThresholds overrides it per "address:port".
+	TCPRemoteThreshold  float64
+	TCPRemoteThresholds map[string]float64
+	// PressureThresholds limits the 60 second PSI averages in percent, keyed "resource_kind"
+	// such as "memory_full"; pairs not listed are not checked
+	PressureThresholds map[string]float64
+	// Container defaults apply to every container; ContainerRules overrides them per container name
+	ContainerCPUThreshold float64 // percent of one core; 0 disables the check
+	ContainerMemThreshold float64 // percent of the container memory limit; 0 disables the check
+	ContainerRules        map[string]ContainerRule
+	// ProcessWatches are evaluated on SSH targets; agents use their own AGENT_PROCESS_WATCH_FILE
+	ProcessWatches []m.ProcessMatcher
+	LogThresholds  bool
+	// CollectorFailAlertAfter is the number of consecutive samples a sub-collector may fail
+	// before alerting; 0 disables the alert
+	CollectorFailAlertAfter int
+	// SSHDefaults holds the SSH_* credentials and host key policy; SSHTargets overrides them per host
+	SSHDefaults SSHTarget
+	SSHTargets  []SSHTarget
+	// SSHKeepalive is the keepalive interval of pooled SSH connections; 0 disables keepalives.
+	// SSHMaxSessions limits the concurrent sessions per SSH host; 0 leaves them unlimited.
+	SSHKeepalive   time.Duration
+	SSHMaxSessions int
+	// IngestToken is the bearer token agents present when pushing metrics; empty disables /api/v1/ingest
+	IngestToken string
+	// Credentials presented to HTTP agents; the TLS files can be overridden in the target URL
+	AgentToken      string            // bearer token (AGENT_TOKEN)
+	AgentTokens     map[string]string // bearer tokens per agent host:port (AGENT_TOKENS)
+	AgentCACert     string            // CA used to verify agent certificates
+	AgentClientCert string            // client certificate for agents that require mutual TLS
+	AgentClientKey  string
+}
+
+// ContainerRule holds the alert limits for one container; 0 disables a check
+type ContainerRule struct {
+	CPUThreshold float64
+	MemThreshold float64
+	MustRun      bool // alert when the container is missing or not running
 }
 
 func LoadConfig() Config {
@@ -26,35 +86,140 @@ func LoadConfig() Config {
 			}
 		}
 	}
+	// Bearer tokens per HTTP agent: AGENT_TOKENS="10.0.0.11:9123=TOKEN,10.0.0.12:9123=TOKEN2"
+	agentTokens := stringMapEnv("AGENT_TOKENS")
+	for i, a := range agents {
+		target, host, token := splitTargetToken(a)
+		if token == "" {
+			continue
+		}
+		log.Printf("SERVERS: token of %s moved out of the target URL, set it in AGENT_TOKENS instead", utils.MaskPassword(a))
+		agents[i] = target
+		if _, ok := agentTokens[host]; !ok {
+			agentTokens[host] = token
+		}
+	}
 	poll := 5 * time.Second
 	if v := os.Getenv("POLL_INTERVAL_SECONDS"); v != "" {
 		if n, err := strconv.Atoi(v); err == nil && n > 0 {
 			poll = time.Duration(n) * time.Second
 		}
 	}
-	cpuTh := 80.0
-	if v := os.Getenv("CPU_THRESHOLD_PERCENT"); v != "" {
-		if n, err := strconv.ParseFloat(v, 64); err == nil {
-			cpuTh = n
+	cpuTh := floatEnv("CPU_THRESHOLD_PERCENT", 80.0)
+	loadTh := floatEnv("LOAD_PER_CORE_THRESHOLD", 0)
+	iowaitTh := floatEnv("CPU_IOWAIT_THRESHOLD_PERCENT", 0)
+	memTh := floatEnv("MEM_THRESHOLD_PERCENT", 90.0)
+	swapTh := floatEnv("SWAP_THRESHOLD_PERCENT", 0)
+	diskTh := floatEnv("DISK_THRESHOLD_PERCENT", 90.0)
+	inodeTh := floatEnv("INODE_THRESHOLD_PERCENT", 0)
+	// Process RAM threshold (percent)
+	procTh := floatEnv("PROC_RAM_THRESHOLD_PERCENT", 90.0)
+	// Process CPU threshold (percent of one core), disabled by default
+	procCPUTh := floatEnv("PROC_CPU_THRESHOLD_PERCENT", 0)
+	ioUtilTh := floatEnv("DISK_IO_UTIL_THRESHOLD_PERCENT", 0)
+	ioAwaitTh := floatEnv("DISK_IO_AWAIT_THRESHOLD_MS", 0)
+	netErrTh := floatEnv("NET_ERROR_THRESHOLD_PER_SEC", 0)
+	netTputTh := floatEnv("NET_THROUGHPUT_THRESHOLD_BYTES_PER_SEC", 0)
+	tempTh := floatEnv("TEMP_THRESHOLD_CELSIUS", 0)
+	// Per-sensor overrides: TEMP_SENSOR_THRESHOLDS="coretemp_package_id_0=90,acpitz=70"
+	sensorTh := floatMapEnv("TEMP_SENSOR_THRESHOLDS")
+	// TCP socket counts per state, TCP_STATE_THRESHOLDS="CLOSE_WAIT=50,TIME_WAIT=5000",
+	// and connections per remote endpoint, with overrides in TCP_REMOTE_THRESHOLDS="10.0.0.5:5432=200"
+	tcpStateTh := floatMapEnv("TCP_STATE_THRESHOLDS")
+	tcpRemoteTh := floatEnv("TCP_REMOTE_CONN_THRESHOLD", 0)
+	tcpRemoteOverrides := floatMapEnv("TCP_REMOTE_THRESHOLDS")
+	// Pressure stall averages: PSI_THRESHOLDS="memory_full=5,io_full=10,cpu_some=50"
+	psiTh := floatMapEnv("PSI_THRESHOLDS")
+	for name := range psiTh {
+		resource, kind, _ := strings.Cut(name, "_")
+		if (resource != "cpu" && resource != "memory" && resource != "io") || (kind != "some" && kind != "full") {
+			log.Printf("PSI_THRESHOLDS: ignoring %q, expected cpu|memory|io followed by _some or _full", name)
+			delete(psiTh, name)
 		}
 	}
-	memTh := 90.0
-	if v := os.Getenv("MEM_THRESHOLD_PERCENT"); v != "" {
-		if n, err := strconv.ParseFloat(v, 64); err == nil {
-			memTh = n
+	ctCPUTh := floatEnv("CONTAINER_CPU_THRESHOLD_PERCENT", 0)
+	ctMemTh := floatEnv("CONTAINER_MEM_THRESHOLD_PERCENT", 0)
+	// Per-container rules: CONTAINER_ALERT_RULES="postgres:mem=95:running,nginx:cpu=150"
+	ctRules := map[string]ContainerRule{}
+	if v := os.Getenv("CONTAINER_ALERT_RULES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			fields := strings.Split(entry, ":")
+			name := strings.TrimSpace(fields[0])
+			if name == "" {
+				continue
+			}
+			rule := ContainerRule{CPUThreshold: ctCPUTh, MemThreshold: ctMemTh}
+			for _, f := range fields[1:] {
+				key, val, _ := strings.Cut(strings.TrimSpace(f), "=")
+				n, _ := strconv.ParseFloat(val, 64)
+				switch key {
+				case "cpu":
+					rule.CPUThreshold = n
+				case "mem":
+					rule.MemThreshold = n
+				case "running":
+					rule.MustRun = true
+				}
+			}
+			ctRules[name] = rule
 		}
 	}
-	diskTh := 90.0
-	if v := os.Getenv("DISK_THRESHOLD_PERCENT"); v != "" {
-		if n, err := strconv.ParseFloat(v, 64); err == nil {
-			diskTh = n
+
+	// Process watch list for SSH targets: PROCESS_WATCH_FILE=/etc/monserv/processes.json
+	var procWatches []m.ProcessMatcher
+	if path := os.Getenv("PROCESS_WATCH_FILE"); path != "" {
+		var err error
+		if procWatches, err = m.LoadProcessMatchers(path); err != nil {
+			log.Fatalf("process watches: %v", err)
 		}
 	}
-	// Process RAM threshold (percent)
-	procTh := 90.0
-	if v := os.Getenv("PROC_RAM_THRESHOLD_PERCENT"); v != "" {
-		if n, err := strconv.ParseFloat(v, 64); err == nil {
-			procTh = n
+
+	// SSH keys and host key checking, per host in SSH_TARGETS_FILE=/etc/monserv/ssh_targets.json
+	sshDefaults := SSHTarget{
+		KeyFile:       os.Getenv("SSH_KEY_FILE"),
+		KeyPassphrase: os.Getenv("SSH_KEY_PASSPHRASE"),
+		KnownHosts:    os.Getenv("SSH_KNOWN_HOSTS"),
+		HostKeyCheck:  os.Getenv("SSH_HOST_KEY_CHECK"),
+		ProxyJump:     os.Getenv("SSH_PROXY_JUMP"),
+	}
+	if v := os.Getenv("SSH_USE_AGENT"); v != "" {
+		useAgent := isTrue(v)
+		sshDefaults.UseAgent = &useAgent
+	}
+	switch sshDefaults.HostKeyCheck {
+	case "", hostKeyStrict, hostKeyTOFU, hostKeyOff:
+	default:
+		log.Fatalf("SSH_HOST_KEY_CHECK must be strict, tofu or off, got %q", sshDefaults.HostKeyCheck)
+	}
+	if _, err := parseProxyJump(sshDefaults.ProxyJump); err != nil {
+		log.Fatalf("SSH_PROXY_JUMP: %v", err)
+	}
+	var sshTargets []SSHTarget
+	if path := os.Getenv("SSH_TARGETS_FILE"); path != "" {
+		var err error
+		if sshTargets, err = LoadSSHTargets(path); err != nil {
+			log.Fatalf("SSH targets: %v", err)
+		}
+	}
+
+	// Pooled SSH connections: SSH_KEEPALIVE_SECONDS=30, SSH_MAX_SESSIONS=4
+	sshKeepalive := 30 * time.Second
+	if v := os.Getenv("SSH_KEEPALIVE_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			sshKeepalive = time.Duration(n) * time.Second
+		}
+	}
+	sshMaxSessions := 4
+	if v := os.Getenv("SSH_MAX_SESSIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			sshMaxSessions = n
+		}
+	}
+
+	collectorFailAfter := 0
+	if v := os.Getenv("COLLECTOR_FAIL_ALERT_AFTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			collectorFailAfter = n
 		}
 	}
 
@@ -67,7 +232,106 @@ func LoadConfig() Config {
 		logTh = isTrue(v)
 	}
 
-	return Config{Agents: agents, PollInterval: poll, CPUThreshold: cpuTh, MemThreshold: memTh, DiskThreshold: diskTh, ProcThreshold: procTh, LogThresholds: logTh}
+	return Config{
+		Agents:                  agents,
+		PollInterval:            poll,
+		CPUThreshold:            cpuTh,
+		LoadPerCoreThreshold:    loadTh,
+		IOWaitThreshold:         iowaitTh,
+		MemThreshold:            memTh,
+		SwapThreshold:           swapTh,
+		DiskThreshold:           diskTh,
+		InodeThreshold:          inodeTh,
+		ProcThreshold:           procTh,
+		ProcCPUThreshold:        procCPUTh,
+		DiskIOUtilThreshold:     ioUtilTh,
+		DiskIOAwaitThreshold:    ioAwaitTh,
+		NetErrorThreshold:       netErrTh,
+		NetThroughputThreshold:  netTputTh,
+		TempThreshold:           tempTh,
+		SensorThresholds:        sensorTh,
+		TCPStateThresholds:      tcpStateTh,
+		TCPRemoteThreshold:      tcpRemoteTh,
+		TCPRemoteThresholds:     tcpRemoteOverrides,
+		PressureThresholds:      psiTh,
+		ContainerCPUThreshold:   ctCPUTh,
+		ContainerMemThreshold:   ctMemTh,
+		ContainerRules:          ctRules,
+		ProcessWatches:          procWatches,
+		LogThresholds:           logTh,
+		CollectorFailAlertAfter: collectorFailAfter,
+		SSHDefaults:             sshDefaults,
+		SSHTargets:              sshTargets,
+		SSHKeepalive:            sshKeepalive,
+		SSHMaxSessions:          sshMaxSessions,
+		IngestToken:             os.Getenv("INGEST_TOKEN"),
+		AgentToken:              os.Getenv("AGENT_TOKEN"),
+		AgentTokens:             agentTokens,
+		AgentCACert:             os.Getenv("AGENT_CA_CERT"),
+		AgentClientCert:         os.Getenv("AGENT_CLIENT_CERT"),
+		AgentClientKey:          os.Getenv("AGENT_CLIENT_KEY"),
+	}
+}
+
+// SensorThreshold returns the temperature limit for a sensor, preferring a per-sensor override
+func (c Config) SensorThreshold(name string) float64 {
+	if th, ok := c.SensorThresholds[name]; ok {
+		return th
+	}
+	return c.TempThreshold
+}
+
+// TCPRemoteLimit returns the connection limit for a remote endpoint, preferring a per-endpoint override
+func (c Config) TCPRemoteLimit(endpoint string) float64 {
+	if th, ok := c.TCPRemoteThresholds[endpoint]; ok {
+		return th
+	}
+	return c.TCPRemoteThreshold
+}
+
+// ContainerRule returns the alert rule for a container, preferring a per-container override
+func (c Config) ContainerRule(name string) ContainerRule {
+	if rule, ok := c.ContainerRules[name]; ok {
+		return rule
+	}
+	return ContainerRule{CPUThreshold: c.ContainerCPUThreshold, MemThreshold: c.ContainerMemThreshold}
+}
+
+// floatMapEnv parses "name=value,name=value" lists; malformed pairs are skipped
+func floatMapEnv(key string) map[string]float64 {
+	out := map[string]float64{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseFloat(strings.TrimSpace(val), 64); err == nil {
+			out[strings.TrimSpace(name)] = n
+		}
+	}
+	return out
+}
+
+// stringMapEnv parses "name=value" pairs separated by commas; values may contain "="
+func stringMapEnv(key string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		name, val, ok := strings.Cut(pair, "=")
+		if name, val = strings.TrimSpace(name), strings.TrimSpace(val); ok && name != "" && val != "" {
+			out[name] = val
+		}
+	}
+	return out
+}
+
+// floatEnv reads a float environment variable, falling back to def when unset or invalid
+func floatEnv(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return def
 }
 
 func isTrue(s string) bool {
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+
+	m "monserv/internal/metrics"
+)
+
+// hostInfoCmd prints the kernel, the os-release file, the uptime and the virtualization
+// hints in one round trip. systemd-detect-virt prints "none" on bare metal and is missing
+// on older hosts, where the hypervisor CPU flag still tells a guest apart.
+const hostInfoCmd = "uname -srm; cat /etc/os-release 2>/dev/null; " +
+	"echo \"MONSERV_UPTIME=$(cut -d' ' -f1 /proc/uptime)\"; " +
+	"echo \"MONSERV_VIRT=$(systemd-detect-virt 2>/dev/null)\"; " +
+	"grep -qw hypervisor /proc/cpuinfo 2>/dev/null && echo MONSERV_HYPERVISOR=1; " +
+	"[ -f /.dockerenv ] && echo MONSERV_DOCKERENV=1; true"
+
+// platformFamilies maps os-release IDs to the platform families gopsutil reports on agents
+var platformFamilies = map[string]string{
+	"debian":    "debian",
+	"ubuntu":    "debian",
+	"raspbian":  "debian",
+	"rhel":      "rhel",
+	"centos":    "rhel",
+	"rocky":     "rhel",
+	"almalinux": "rhel",
+	"ol":        "rhel",
+	"fedora":    "fedora",
+	"suse":      "suse",
+	"opensuse":  "suse",
+	"sles":      "suse",
+	"arch":      "arch",
+	"alpine":    "alpine",
+}
+
+// parseHostInfo parses hostInfoCmd output:
+//
+//	Linux 5.15.0-91-generic x86_64
+//	ID=ubuntu
+//	VERSION_ID="22.04"
+//	MONSERV_UPTIME=86400.52
+//	MONSERV_VIRT=kvm
+//
+// The uptime is returned separately, in whole seconds.
+func parseHostInfo(out string) (m.HostInfo, uint64, bool) {
+	var info m.HostInfo
+	sc := bufio.NewScanner(strings.NewReader(out))
+	if !sc.Scan() {
+		return info, 0, false
+	}
+	f := strings.Fields(sc.Text())
+	if len(f) != 3 {
+		return info, 0, false
+	}
+	info.OS, info.KernelVersion, info.Arch = strings.ToLower(f[0]), f[1], f[2]
+
+	vars := map[string]string{}
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), "="); ok {
+			vars[k] = strings.Trim(v, "\"'")
+		}
+	}
+	info.Platform = vars["ID"]
+	info.PlatformVersion = vars["VERSION_ID"]
+	for _, id := range append([]string{vars["ID"]}, strings.Fields(vars["ID_LIKE"])...) {
+		if fam, ok := platformFamilies[id]; ok {
+			info.PlatformFamily = fam
+			break
+		}
+	}
+
+	switch virt := vars["MONSERV_VIRT"]; {
+	case virt != "" && virt != "none":
+		info.VirtualizationSystem, info.VirtualizationRole = virt, "guest"
+	case vars["MONSERV_DOCKERENV"] == "1":
+		info.VirtualizationSystem, info.VirtualizationRole = "docker", "guest"
+	case vars["MONSERV_HYPERVISOR"] == "1":
+		// a guest of an unknown hypervisor
+		info.VirtualizationRole = "guest"
+	}
+	uptime, _ := strconv.ParseFloat(vars["MONSERV_UPTIME"], 64)
+	return info, uint64(max(0, uptime)), true
+}
+
+// parseBootTime reads the "btime 1700000000" line of /proc/stat
+func parseBootTime(procStat string) time.Time {
+	sc := bufio.NewScanner(strings.NewReader(procStat))
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "btime "); ok {
+			if sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && sec > 0 {
+				return time.Unix(sec, 0).UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// httpTarget is an HTTP(S) agent target with the credentials to present to it.
+// TLS files are read from the target URL itself, so every target can have its own:
+//
+//	https://10.0.0.11:9123?ca=/etc/monserv/ca.pem&cert=/etc/monserv/client.pem&key=/etc/monserv/client.key
+//
+// The token comes from AGENT_TOKENS by host, as it must not be part of the URL the target
+// is known by. Anything not set falls back to the AGENT_* defaults in Config.
+type httpTarget struct {
+	metricsURL string
+	token      string
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+func parseHTTPTarget(raw string, cfg Config) (httpTarget, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return httpTarget{}, err
+	}
+	q := u.Query()
+	t := httpTarget{
+		token:    firstSet(cfg.AgentTokens[u.Host], cfg.AgentToken),
+		caFile:   firstSet(q.Get("ca"), cfg.AgentCACert),
+		certFile: firstSet(q.Get("cert"), cfg.AgentClientCert),
+		keyFile:  firstSet(q.Get("key"), cfg.AgentClientKey),
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Path = strings.TrimRight(u.Path, "/") + "/metrics"
+	t.metricsURL = u.String()
+	return t, nil
+}
+
+// splitTargetToken takes a token given as URL password, as in https://:TOKEN@10.0.0.11:9123,
+// out of an HTTP target. The target URL keys the state and alerts and reaches the
+// WebSocket and notifiers, so the token is moved to AGENT_TOKENS under the returned host.
+func splitTargetToken(raw string) (target, host, token string) {
+	if strings.HasPrefix(raw, "ssh://") {
+		return raw, "", ""
+	}
+	s := raw
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return raw, "", ""
+	}
+	token, _ = u.User.Password()
+	if token == "" {
+		return raw, "", ""
+	}
+	u.User = nil
+	return u.String(), u.Host, token
+}
+
+// usesTLSFiles reports whether the target needs its own TLS client configuration
+func (t httpTarget) usesTLSFiles() bool {
+	return t.caFile != "" || t.certFile != ""
+}
+
+// client builds an HTTP client that verifies the agent against caFile and
+// presents the client certificate when one is configured
+func (t httpTarget) client(base *http.Client) (*http.Client, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.caFile != "" {
+		pem, err := os.ReadFile(t.caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.caFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if t.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{
+		Timeout:   base.Timeout,
+		Transport: &http.Transport{TLSClientConfig: tlsCfg, Proxy: http.ProxyFromEnvironment},
+	}, nil
+}
+
+func firstSet(v, def string) string {
+	if v != "" {
+		return v
+	}
+	return def
+}
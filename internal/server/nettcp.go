@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	m "monserv/internal/metrics"
+)
+
+// procNetTCPCmd dumps the IPv4 and IPv6 TCP socket tables; hosts without IPv6 lack tcp6
+const procNetTCPCmd = "cat /proc/net/tcp && { cat /proc/net/tcp6 2>/dev/null; true; }"
+
+// socketOwnersCmd lists the socket links of every process fd directory the SSH user can read
+const socketOwnersCmd = "ls -l /proc/[0-9]*/fd 2>/dev/null | grep -e '^/proc/' -e 'socket:'"
+
+// tcpStates maps the kernel state codes of /proc/net/tcp to the names gopsutil reports
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// parseProcNetTCP parses /proc/net/tcp and /proc/net/tcp6 rows into connections and
+// returns the socket inode of each one, index aligned, for owner lookup
+//
+//	sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+//	 0: 0100007F:0CEA 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 21512 ...
+func parseProcNetTCP(out string) ([]m.TCPConn, []string) {
+	conns := []m.TCPConn{}
+	inodes := []string{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 10 || f[0] == "sl" {
+			continue
+		}
+		laddr, lport, ok1 := decodeProcNetAddr(f[1])
+		raddr, rport, ok2 := decodeProcNetAddr(f[2])
+		state, ok3 := tcpStates[f[3]]
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+		c := m.TCPConn{State: state, LocalAddr: laddr, LocalPort: lport}
+		if state != "LISTEN" {
+			c.RemoteAddr, c.RemotePort = raddr, rport
+		}
+		conns = append(conns, c)
+		inodes = append(inodes, f[9])
+	}
+	return conns, inodes
+}
+
+// decodeProcNetAddr decodes "0100007F:0CEA" into 127.0.0.1 and 3306. The address is
+// stored as 32-bit words in host byte order (little-endian on the targets we support).
+func decodeProcNetAddr(s string) (string, uint32, bool) {
+	a, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	raw, err := hex.DecodeString(a)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return "", 0, false
+	}
+	for i := 0; i < len(raw); i += 4 {
+		raw[i], raw[i+1], raw[i+2], raw[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	ip, _ := netip.AddrFromSlice(raw)
+	// IPv4 peers of dual-stack sockets appear as ::ffff:a.b.c.d
+	return ip.Unmap().String(), uint32(port), true
+}
+
+// parseSocketOwners maps socket inodes to PIDs from socketOwnersCmd output:
+//
+//	/proc/812/fd:
+//	lrwx------ 1 root root 64 Oct 16 23:00 3 -> socket:[21512]
+func parseSocketOwners(out string) map[string]int32 {
+	owners := map[string]int32{}
+	var pid int32
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if dir, ok := strings.CutPrefix(line, "/proc/"); ok {
+			n, _ := strconv.ParseInt(strings.TrimSuffix(dir, "/fd:"), 10, 32)
+			pid = int32(n)
+			continue
+		}
+		_, target, ok := strings.Cut(line, "-> socket:[")
+		if !ok || pid == 0 {
+			continue
+		}
+		if inode := strings.TrimSuffix(target, "]"); inode != "" {
+			if _, seen := owners[inode]; !seen {
+				owners[inode] = pid
+			}
+		}
+	}
+	return owners
+}
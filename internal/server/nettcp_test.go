@@ -0,0 +1,74 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	m "monserv/internal/metrics"
+)
+
+func TestDecodeProcNetAddr(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantAddr string
+		wantPort uint32
+		wantOK   bool
+	}{
+		{in: "0100007F:0CEA", wantAddr: "127.0.0.1", wantPort: 3306, wantOK: true},
+		{in: "00000000:0016", wantAddr: "0.0.0.0", wantPort: 22, wantOK: true},
+		{in: "0500000A:1538", wantAddr: "10.0.0.5", wantPort: 5432, wantOK: true},
+		{in: "00000000000000000000000000000000:01BB", wantAddr: "::", wantPort: 443, wantOK: true},
+		{in: "00000000000000000000000001000000:1F90", wantAddr: "::1", wantPort: 8080, wantOK: true},
+		// every 32-bit word is byte swapped, not the address as a whole
+		{in: "B80D0120000000000000000005000000:1538", wantAddr: "2001:db8::5", wantPort: 5432, wantOK: true},
+		{in: "000080FE00000000FF0F02021E4B56FE:C350", wantAddr: "fe80::202:fff:fe56:4b1e", wantPort: 50000, wantOK: true},
+		// IPv4 peer of a dual-stack socket
+		{in: "0000000000000000FFFF00000500000A:D431", wantAddr: "10.0.0.5", wantPort: 54321, wantOK: true},
+		{in: "0100007F", wantOK: false},
+		{in: "0100007F:XYZ", wantOK: false},
+		{in: "01007F:0016", wantOK: false},
+		{in: "0100007G:0016", wantOK: false},
+		{in: "0100007F:10000", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			addr, port, ok := decodeProcNetAddr(tt.in)
+			if ok != tt.wantOK || addr != tt.wantAddr || port != tt.wantPort {
+				t.Errorf("decodeProcNetAddr(%q) = %q, %d, %v, want %q, %d, %v", tt.in, addr, port, ok, tt.wantAddr, tt.wantPort, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseProcNetTCP(t *testing.T) {
+	out := "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode                                                     \n" +
+		"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 662 1 0000000059ab9d0b 100 0 0 10 0                       \n" +
+		"   1: 0100007F:BC8F 00000000:0000 0A 00000000:00000000 00:00000000 00000000 65534        0 1054 1 00000000b7d97629 100 0 0 10 0                      \n" +
+		"   2: 0F02000A:A2C4 0500000A:1538 01 00000000:00000000 02:000A6B1D 00000000   999        0 21512 2 00000000a1b2c3d4 20 4 30 10 -1                    \n" +
+		"   3: 0F02000A:A2C6 0500000A:1538 08 00000000:00000001 00:00000000 00000000   999        0 21513 1 00000000a1b2c3d5 20 4 30 10 -1                    \n" +
+		"   4: 0F02000A:0016 0A01A8C0:E2B4 06 00000000:00000000 03:00001770 00000000     0        0 0 3 00000000a1b2c3d6\n" +
+		"   5: 0F02000A:0016 0A01A8C0:E2B6 0C 00000000:00000000 00:00000000 00000000     0        0 21600 1 00000000a1b2c3d7\n" +
+		"  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+		"   0: 00000000000000000000000000000000:01BB 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1200 1 00000000e1e2e3e4 100 0 0 10 0\n" +
+		"   1: 0000000000000000FFFF00000F02000A:01BB 0000000000000000FFFF00000A01A8C0:E2C0 01 00000000:00000000 02:00000A3C 00000000    33        0 1300 1 00000000e1e2e3e5 20 4 31 10 -1\n" +
+		"   2: B80D0120000000000000000001000000:1F90 B80D0120000000000000000005000000:A000 01 00000000:00000000 00:00000000 00000000  1000        0 1400 1 00000000e1e2e3e6 20 4 31 10 -1\n"
+	wantConns := []m.TCPConn{
+		{State: "LISTEN", LocalAddr: "0.0.0.0", LocalPort: 22},
+		{State: "LISTEN", LocalAddr: "127.0.0.1", LocalPort: 48271},
+		{State: "ESTABLISHED", LocalAddr: "10.0.2.15", LocalPort: 41668, RemoteAddr: "10.0.0.5", RemotePort: 5432},
+		{State: "CLOSE_WAIT", LocalAddr: "10.0.2.15", LocalPort: 41670, RemoteAddr: "10.0.0.5", RemotePort: 5432},
+		{State: "TIME_WAIT", LocalAddr: "10.0.2.15", LocalPort: 22, RemoteAddr: "192.168.1.10", RemotePort: 58036},
+		{State: "LISTEN", LocalAddr: "::", LocalPort: 443},
+		{State: "ESTABLISHED", LocalAddr: "10.0.2.15", LocalPort: 443, RemoteAddr: "192.168.1.10", RemotePort: 58048},
+		{State: "ESTABLISHED", LocalAddr: "2001:db8::1", LocalPort: 8080, RemoteAddr: "2001:db8::5", RemotePort: 40960},
+	}
+	// the NEW_SYN_RECV row (0C) is not a state gopsutil reports and is skipped
+	wantInodes := []string{"662", "1054", "21512", "21513", "0", "1200", "1300", "1400"}
+	conns, inodes := parseProcNetTCP(out)
+	if !reflect.DeepEqual(conns, wantConns) {
+		t.Errorf("conns = %+v, want %+v", conns, wantConns)
+	}
+	if !reflect.DeepEqual(inodes, wantInodes) {
+		t.Errorf("inodes = %q, want %q", inodes, wantInodes)
+	}
+}
@@ -12,12 +12,14 @@ import (
 	m "monserv/internal/metrics"
 	"monserv/internal/notifier"
 	"monserv/internal/repository"
+	"monserv/internal/utils"
 )
 
 // WebSocketBroadcaster interface untuk broadcast metrics via WebSocket
 type WebSocketBroadcaster interface {
 	BroadcastMetrics(state map[string]*m.ServerMetrics)
 	BroadcastAlert(alertType, subject, message string)
+	BroadcastEvent(e m.Event)
 }
 
 type State struct {
@@ -50,6 +52,19 @@ type Poller struct {
 	Client   *http.Client
 	Repo     repository.MetricsRepository // Tambahan untuk sync ke repository
 	WSHub    WebSocketBroadcaster         // Tambahan untuk WebSocket broadcast
+
+	// TLS clients per HTTP target, built once from the target's certificate files
+	clientMu sync.Mutex
+	clients  map[string]*http.Client
+
+	// SSH connections reused across polls, closed when the poller stops
+	SSH *SSHPool
+
+	// Consecutive failed samples per agent and sub-collector, keyed like alerts
+	failMu         sync.Mutex
+	collectorFails map[string]int
+	// Last connection error per SSH target, so that an error is logged once, not every poll
+	sshErrs map[string]string
 }
 
 func NewPoller(cfg Config, n notifier.Notifier) *Poller {
@@ -58,6 +73,7 @@ func NewPoller(cfg Config, n notifier.Notifier) *Poller {
 		State:    NewState(cfg.Agents),
 		Notifier: n,
 		Client:   &http.Client{Timeout: 5 * time.Second},
+		SSH:      NewSSHPool(cfg.SSHKeepalive, cfg.SSHMaxSessions),
 		Repo:     nil, // akan diset dari main.go
 		WSHub:    nil, // akan diset dari main.go
 	}
@@ -69,6 +85,11 @@ func (p *Poller) Snapshot() ([]string, map[string]*m.ServerMetrics) { return p.S
 func (p *Poller) Start(stop <-chan struct{}) {
 	ticker := time.NewTicker(p.Cfg.PollInterval)
 	defer ticker.Stop()
+	go func() {
+		// closing the pool right away cuts SSH collections still in flight
+		<-stop
+		p.SSH.Close()
+	}()
 	p.runOnce()
 	for {
 		select {
@@ -91,22 +112,14 @@ func (p *Poller) runOnce() {
 			if strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
 				met, err = p.fetchHTTP(u)
 			} else if strings.HasPrefix(u, "ssh://") {
-				met, err = CollectViaSSH(u)
+				met, err = CollectViaSSH(u, p.Cfg, p.SSH)
+				p.logSSHResult(u, err)
 			} else {
 				// Default assume HTTP base
 				met, err = p.fetchHTTP(u)
 			}
 			if err == nil && met != nil {
-				p.State.mu.Lock()
-				p.State.Latest[u] = met
-				p.State.mu.Unlock()
-
-				// Sync ke repository jika tersedia
-				if p.Repo != nil {
-					p.Repo.Set(u, met)
-				}
-
-				p.checkAlerts(u, met)
+				p.record(u, met)
 			}
 		}(url)
 	}
@@ -119,8 +132,91 @@ func (p *Poller) runOnce() {
 	}
 }
 
+// logSSHResult logs an SSH target's connection error when it changes, and the recovery
+// once it connects again. The URL is masked; SSH errors themselves carry no credentials.
+func (p *Poller) logSSHResult(target string, err error) {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	p.failMu.Lock()
+	prev := p.sshErrs[target]
+	if p.sshErrs == nil {
+		p.sshErrs = map[string]string{}
+	}
+	if msg == "" {
+		delete(p.sshErrs, target)
+	} else {
+		p.sshErrs[target] = msg
+	}
+	p.failMu.Unlock()
+	switch {
+	case msg != "" && msg != prev:
+		log.Printf("[SSH] %s: %s", utils.MaskPassword(target), msg)
+	case msg == "" && prev != "":
+		log.Printf("[SSH] %s: connected again", utils.MaskPassword(target))
+	}
+}
+
+// Ingest records metrics pushed by an agent. Pushed hosts go through the same state,
+// repository, alert and WebSocket flow as polled ones.
+func (p *Poller) Ingest(source string, met *m.ServerMetrics) {
+	p.State.mu.Lock()
+	known := false
+	for _, a := range p.State.Agents {
+		if a == source {
+			known = true
+			break
+		}
+	}
+	if !known {
+		p.State.Agents = append(p.State.Agents, source)
+	}
+	p.State.mu.Unlock()
+
+	p.record(source, met)
+
+	if p.WSHub != nil {
+		_, latest := p.State.Snapshot()
+		p.WSHub.BroadcastMetrics(latest)
+	}
+}
+
+// record stores a fresh sample of a target and evaluates its alerts. Collectors report
+// sensor names as read; repeated ones are suffixed here, as in the Prometheus output, so
+// that alerts and per-sensor thresholds address each sensor.
+func (p *Poller) record(u string, met *m.ServerMetrics) {
+	m.DedupeSensorNames(met.Sensors)
+	p.State.mu.Lock()
+	prev := p.State.Latest[u]
+	p.State.Latest[u] = met
+	p.State.mu.Unlock()
+
+	// Sync ke repository jika tersedia
+	if p.Repo != nil {
+		p.Repo.Set(u, met)
+	}
+
+	p.checkAlerts(u, prev, met)
+}
+
 func (p *Poller) fetchHTTP(base string) (*m.ServerMetrics, error) {
-	resp, err := p.Client.Get(fmt.Sprintf("%s/metrics", base))
+	target, err := parseHTTPTarget(base, p.Cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, err := p.httpClient(base, target)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, target.metricsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if target.token != "" {
+		req.Header.Set("Authorization", "Bearer "+target.token)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -135,35 +231,133 @@ func (p *Poller) fetchHTTP(base string) (*m.ServerMetrics, error) {
 	return &out, nil
 }
 
-func (p *Poller) checkAlerts(agentURL string, mtr *m.ServerMetrics) {
+// httpClient returns the client for a target, building and caching a TLS client
+// when the target has its own CA or client certificate
+func (p *Poller) httpClient(base string, target httpTarget) (*http.Client, error) {
+	if !target.usesTLSFiles() {
+		return p.Client, nil
+	}
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+	if c, ok := p.clients[base]; ok {
+		return c, nil
+	}
+	c, err := target.client(p.Client)
+	if err != nil {
+		return nil, err
+	}
+	if p.clients == nil {
+		p.clients = map[string]*http.Client{}
+	}
+	p.clients[base] = c
+	return c, nil
+}
+
+// checkAlerts evaluates thresholds for a fresh sample; prev is the previous sample of the
+// same target, or nil on the first successful poll
+func (p *Poller) checkAlerts(agentURL string, prev, mtr *m.ServerMetrics) {
 	if p.Cfg.LogThresholds {
 		log.Printf("[THRESHOLD] host=%s cpu=%.1f%% th=%.1f%%", mtr.Hostname, mtr.CPU.UsedPercent, p.Cfg.CPUThreshold)
+		log.Printf("[THRESHOLD] host=%s load5/core=%.2f th=%.2f iowait=%.1f%% th=%.1f%%", mtr.Hostname, mtr.CPU.LoadPerCore(), p.Cfg.LoadPerCoreThreshold, mtr.CPU.IOWaitPercent, p.Cfg.IOWaitThreshold)
 		log.Printf("[THRESHOLD] host=%s mem=%.1f%% th=%.1f%%", mtr.Hostname, mtr.Memory.UsedPercent, p.Cfg.MemThreshold)
+		log.Printf("[THRESHOLD] host=%s swap=%.1f%% in=%.0fB/s out=%.0fB/s th=%.1f%%", mtr.Hostname, mtr.Memory.SwapUsedPercent, mtr.Memory.SwapInPerSec, mtr.Memory.SwapOutPerSec, p.Cfg.SwapThreshold)
 		for _, d := range mtr.Disks {
 			log.Printf("[THRESHOLD] host=%s mount=%s disk=%.1f%% th=%.1f%%", mtr.Hostname, d.Mountpoint, d.UsedPercent, p.Cfg.DiskThreshold)
+			log.Printf("[THRESHOLD] host=%s mount=%s inodes=%.1f%% th=%.1f%%", mtr.Hostname, d.Mountpoint, d.InodesUsedPercent, p.Cfg.InodeThreshold)
+		}
+		for _, d := range mtr.DiskIO {
+			log.Printf("[THRESHOLD] host=%s dev=%s util=%.1f%% await=%.1fms th=%.1f%%", mtr.Hostname, d.Device, d.UtilPercent, d.AwaitMs, p.Cfg.DiskIOUtilThreshold)
+		}
+		for _, n := range mtr.Network {
+			log.Printf("[THRESHOLD] host=%s iface=%s rx=%.0fB/s tx=%.0fB/s err=%.1f/s drop=%.1f/s th=%.1f/s", mtr.Hostname, n.Name, n.BytesRecvPerSec, n.BytesSentPerSec, n.ErrorsPerSec, n.DropsPerSec, p.Cfg.NetErrorThreshold)
+		}
+		for _, sn := range mtr.Sensors {
+			log.Printf("[THRESHOLD] host=%s sensor=%s temp=%.1fC th=%.1fC", mtr.Hostname, sn.Name, sn.TemperatureC, p.Cfg.SensorThreshold(sn.Name))
+		}
+		for _, ct := range mtr.Containers {
+			rule := p.Cfg.ContainerRule(ct.Name)
+			log.Printf("[THRESHOLD] host=%s container=%s state=%s cpu=%.1f%% th=%.1f%% mem=%.1f%% th=%.1f%% restarts=%d", mtr.Hostname, ct.Name, ct.State, ct.CPUPercent, rule.CPUThreshold, ct.MemoryPercent, rule.MemThreshold, ct.RestartCount)
+		}
+		for _, u := range mtr.SystemdUnits {
+			log.Printf("[THRESHOLD] host=%s unit=%s load=%s active=%s sub=%s restarts=%d", mtr.Hostname, u.Name, u.LoadState, u.ActiveState, u.SubState, u.Restarts)
+		}
+		for _, ck := range mtr.Checks {
+			log.Printf("[THRESHOLD] host=%s check=%s status=%s", mtr.Hostname, ck.Name, ck.Status)
+		}
+		for state, th := range p.Cfg.TCPStateThresholds {
+			log.Printf("[THRESHOLD] host=%s tcp_state=%s count=%d th=%.0f", mtr.Hostname, state, mtr.TCP.States[state], th)
+		}
+		for _, r := range mtr.TCP.TopRemotes {
+			log.Printf("[THRESHOLD] host=%s tcp_remote=%s count=%d close_wait=%d th=%.0f", mtr.Hostname, r.Endpoint(), r.Count, r.CloseWait, p.Cfg.TCPRemoteLimit(r.Endpoint()))
+		}
+		for _, w := range mtr.ProcessWatches {
+			log.Printf("[THRESHOLD] host=%s watch=%s count=%d min=%d pids=%v", mtr.Hostname, w.Name, w.Count, w.MinCount, w.PIDs)
+		}
+		for _, lw := range mtr.LogWatches {
+			log.Printf("[THRESHOLD] host=%s log=%s matches=%d/%ds th=%d", mtr.Hostname, lw.Name, lw.Matches, lw.WindowSeconds, lw.Threshold)
 		}
 		for _, pr := range mtr.TopProcsByMem {
 			log.Printf("[THRESHOLD] host=%s pid=%d name=%s ram=%.1f%% th=%.1f%%", mtr.Hostname, pr.PID, pr.Name, pr.PercentRAM, p.Cfg.ProcThreshold)
 		}
+		for _, pr := range mtr.TopProcsByCPU {
+			log.Printf("[THRESHOLD] host=%s pid=%d name=%s cpu=%.1f%% th=%.1f%%", mtr.Hostname, pr.PID, pr.Name, pr.CPUPercent, p.Cfg.ProcCPUThreshold)
+		}
 	}
-	// CPU threshold
+	// CPU threshold; a failed cpu collector reports 0%, which must not read as a recovery
 	keyCPU := fmt.Sprintf("%s|cpu", agentURL)
-	if mtr.CPU.UsedPercent >= p.Cfg.CPUThreshold {
+	if _, failed := mtr.CollectionErrors["cpu"]; failed {
+		// keep the alert state until the next complete sample
+	} else if mtr.CPU.UsedPercent >= p.Cfg.CPUThreshold {
 		p.raiseOnce(keyCPU, fmt.Sprintf("[ALERT] %s CPU high", mtr.Hostname),
 			fmt.Sprintf("CPU used %.1f%% (threshold %.1f%%)", mtr.CPU.UsedPercent, p.Cfg.CPUThreshold))
 	} else {
 		p.recoverIfActive(keyCPU, fmt.Sprintf("[RECOVERED] %s CPU", mtr.Hostname),
 			fmt.Sprintf("CPU back to %.1f%%", mtr.CPU.UsedPercent))
 	}
-	// Memory threshold
+	// Load per core threshold
+	if p.Cfg.LoadPerCoreThreshold > 0 {
+		keyLoad := fmt.Sprintf("%s|load", agentURL)
+		if lpc := mtr.CPU.LoadPerCore(); lpc >= p.Cfg.LoadPerCoreThreshold {
+			p.raiseOnce(keyLoad, fmt.Sprintf("[ALERT] %s CPU load high", mtr.Hostname),
+				fmt.Sprintf("Load %.2f on %d cores = %.2f per core (threshold %.2f)", mtr.CPU.Load5, mtr.CPU.Cores, lpc, p.Cfg.LoadPerCoreThreshold))
+		} else {
+			p.recoverIfActive(keyLoad, fmt.Sprintf("[RECOVERED] %s CPU load", mtr.Hostname),
+				fmt.Sprintf("Load back to %.2f per core", lpc))
+		}
+	}
+	// IOWait threshold
+	if _, failed := mtr.CollectionErrors["cpu"]; !failed && p.Cfg.IOWaitThreshold > 0 {
+		keyIOWait := fmt.Sprintf("%s|iowait", agentURL)
+		if mtr.CPU.IOWaitPercent >= p.Cfg.IOWaitThreshold {
+			p.raiseOnce(keyIOWait, fmt.Sprintf("[ALERT] %s CPU iowait high", mtr.Hostname),
+				fmt.Sprintf("CPU iowait %.1f%% (threshold %.1f%%)", mtr.CPU.IOWaitPercent, p.Cfg.IOWaitThreshold))
+		} else {
+			p.recoverIfActive(keyIOWait, fmt.Sprintf("[RECOVERED] %s CPU iowait", mtr.Hostname),
+				fmt.Sprintf("CPU iowait back to %.1f%%", mtr.CPU.IOWaitPercent))
+		}
+	}
+	// Memory threshold, skipped like CPU when the sample is missing
 	keyMem := fmt.Sprintf("%s|mem", agentURL)
-	if mtr.Memory.UsedPercent >= p.Cfg.MemThreshold {
+	if _, failed := mtr.CollectionErrors["memory"]; failed {
+		// keep the alert state until the next complete sample
+	} else if mtr.Memory.UsedPercent >= p.Cfg.MemThreshold {
 		p.raiseOnce(keyMem, fmt.Sprintf("[ALERT] %s memory high", mtr.Hostname),
 			fmt.Sprintf("Memory used %.1f%% (threshold %.1f%%)", mtr.Memory.UsedPercent, p.Cfg.MemThreshold))
 	} else {
 		p.recoverIfActive(keyMem, fmt.Sprintf("[RECOVERED] %s memory", mtr.Hostname),
 			fmt.Sprintf("Memory back to %.1f%%", mtr.Memory.UsedPercent))
 	}
+	// Swap threshold, only meaningful on hosts that have swap configured
+	if p.Cfg.SwapThreshold > 0 && mtr.Memory.SwapTotal > 0 {
+		keySwap := fmt.Sprintf("%s|swap", agentURL)
+		if mtr.Memory.SwapUsedPercent >= p.Cfg.SwapThreshold {
+			p.raiseOnce(keySwap, fmt.Sprintf("[ALERT] %s swap high", mtr.Hostname),
+				fmt.Sprintf("Swap used %.1f%% (threshold %.1f%%), in %.0f B/s out %.0f B/s", mtr.Memory.SwapUsedPercent, p.Cfg.SwapThreshold, mtr.Memory.SwapInPerSec, mtr.Memory.SwapOutPerSec))
+		} else {
+			p.recoverIfActive(keySwap, fmt.Sprintf("[RECOVERED] %s swap", mtr.Hostname),
+				fmt.Sprintf("Swap back to %.1f%%", mtr.Memory.SwapUsedPercent))
+		}
+	}
 	// Disk thresholds
 	for _, d := range mtr.Disks {
 		key := fmt.Sprintf("%s|disk|%s", agentURL, d.Mountpoint)
@@ -175,6 +369,105 @@ func (p *Poller) checkAlerts(agentURL string, mtr *m.ServerMetrics) {
 				fmt.Sprintf("Disk %s back to %.1f%%", d.Mountpoint, d.UsedPercent))
 		}
 	}
+	// Inode thresholds
+	if p.Cfg.InodeThreshold > 0 {
+		for _, d := range mtr.Disks {
+			if d.InodesTotal == 0 {
+				continue
+			}
+			key := fmt.Sprintf("%s|inode|%s", agentURL, d.Mountpoint)
+			if d.InodesUsedPercent >= p.Cfg.InodeThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s inodes %s high", mtr.Hostname, d.Mountpoint),
+					fmt.Sprintf("Inodes on %s used %.1f%% (%d of %d, threshold %.1f%%)", d.Mountpoint, d.InodesUsedPercent, d.InodesUsed, d.InodesTotal, p.Cfg.InodeThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s inodes %s", mtr.Hostname, d.Mountpoint),
+					fmt.Sprintf("Inodes on %s back to %.1f%%", d.Mountpoint, d.InodesUsedPercent))
+			}
+		}
+	}
+	// Disk I/O thresholds; rates of a failed collector read 0
+	diskIO := mtr.DiskIO
+	if _, failed := mtr.CollectionErrors["diskio"]; failed {
+		diskIO = nil
+	}
+	for _, d := range diskIO {
+		if p.Cfg.DiskIOUtilThreshold > 0 {
+			key := fmt.Sprintf("%s|diskio|%s|util", agentURL, d.Device)
+			if d.UtilPercent >= p.Cfg.DiskIOUtilThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s disk I/O %s utilisation high", mtr.Hostname, d.Device),
+					fmt.Sprintf("Device %s busy %.1f%% (threshold %.1f%%)", d.Device, d.UtilPercent, p.Cfg.DiskIOUtilThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s disk I/O %s utilisation", mtr.Hostname, d.Device),
+					fmt.Sprintf("Device %s back to %.1f%% busy", d.Device, d.UtilPercent))
+			}
+		}
+		if p.Cfg.DiskIOAwaitThreshold > 0 {
+			key := fmt.Sprintf("%s|diskio|%s|await", agentURL, d.Device)
+			if d.AwaitMs >= p.Cfg.DiskIOAwaitThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s disk I/O %s latency high", mtr.Hostname, d.Device),
+					fmt.Sprintf("Device %s await %.1fms (threshold %.1fms)", d.Device, d.AwaitMs, p.Cfg.DiskIOAwaitThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s disk I/O %s latency", mtr.Hostname, d.Device),
+					fmt.Sprintf("Device %s await back to %.1fms", d.Device, d.AwaitMs))
+			}
+		}
+	}
+	// Network thresholds, skipped like disk I/O
+	network := mtr.Network
+	if _, failed := mtr.CollectionErrors["network"]; failed {
+		network = nil
+	}
+	for _, n := range network {
+		if n.Name == "lo" {
+			continue
+		}
+		if p.Cfg.NetErrorThreshold > 0 {
+			key := fmt.Sprintf("%s|net|%s|errors", agentURL, n.Name)
+			rate := n.ErrorsPerSec + n.DropsPerSec
+			if rate >= p.Cfg.NetErrorThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s net %s errors high", mtr.Hostname, n.Name),
+					fmt.Sprintf("Interface %s errors+drops %.1f/s (threshold %.1f/s)", n.Name, rate, p.Cfg.NetErrorThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s net %s errors", mtr.Hostname, n.Name),
+					fmt.Sprintf("Interface %s errors+drops back to %.1f/s", n.Name, rate))
+			}
+		}
+		if p.Cfg.NetThroughputThreshold > 0 {
+			key := fmt.Sprintf("%s|net|%s|throughput", agentURL, n.Name)
+			rate := max(n.BytesRecvPerSec, n.BytesSentPerSec)
+			if rate >= p.Cfg.NetThroughputThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s net %s throughput high", mtr.Hostname, n.Name),
+					fmt.Sprintf("Interface %s rx %.0f B/s tx %.0f B/s (threshold %.0f B/s)", n.Name, n.BytesRecvPerSec, n.BytesSentPerSec, p.Cfg.NetThroughputThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s net %s throughput", mtr.Hostname, n.Name),
+					fmt.Sprintf("Interface %s back to rx %.0f B/s tx %.0f B/s", n.Name, n.BytesRecvPerSec, n.BytesSentPerSec))
+			}
+		}
+	}
+	// Sensor temperature thresholds
+	for _, sn := range mtr.Sensors {
+		th := p.Cfg.SensorThreshold(sn.Name)
+		if th <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s|temp|%s", agentURL, sn.Name)
+		if sn.TemperatureC >= th {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s temperature %s high", mtr.Hostname, sn.Name),
+				fmt.Sprintf("Sensor %s at %.1f°C (threshold %.1f°C)", sn.Name, sn.TemperatureC, th))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s temperature %s", mtr.Hostname, sn.Name),
+				fmt.Sprintf("Sensor %s back to %.1f°C", sn.Name, sn.TemperatureC))
+		}
+	}
+	p.checkEvents(agentURL, prev, mtr)
+	p.checkContainerAlerts(agentURL, prev, mtr)
+	p.checkUnitAlerts(agentURL, mtr)
+	p.checkPluginAlerts(agentURL, mtr)
+	p.checkLogAlerts(agentURL, mtr)
+	p.checkProcessWatchAlerts(agentURL, prev, mtr)
+	p.checkTCPAlerts(agentURL, mtr)
+	p.checkPressureAlerts(agentURL, mtr)
+	p.checkCollectorAlerts(agentURL, mtr)
 
 	// Processes thresholds
 	above := map[string]bool{}
@@ -189,12 +482,28 @@ func (p *Poller) checkAlerts(agentURL string, mtr *m.ServerMetrics) {
 				fmt.Sprintf("Process %s(%d) back to %.1f%% RAM", pr.Name, pr.PID, pr.PercentRAM))
 		}
 	}
+	// process CPU is a rate over the counter samples too; keep its alerts while they failed
+	_, countersFailed := mtr.CollectionErrors["counters"]
+	if p.Cfg.ProcCPUThreshold > 0 && !countersFailed {
+		for _, pr := range mtr.TopProcsByCPU {
+			k := fmt.Sprintf("%s|proccpu|%d", agentURL, pr.PID)
+			if pr.CPUPercent >= p.Cfg.ProcCPUThreshold {
+				p.raiseOnce(k, fmt.Sprintf("[ALERT] %s proc %s(%d) CPU high", mtr.Hostname, pr.Name, pr.PID),
+					fmt.Sprintf("Process %s(%d) uses %.1f%% CPU (th %.1f%%)", pr.Name, pr.PID, pr.CPUPercent, p.Cfg.ProcCPUThreshold))
+				above[k] = true
+			} else {
+				p.recoverIfActive(k, fmt.Sprintf("[RECOVERED] %s proc %s(%d) CPU", mtr.Hostname, pr.Name, pr.PID),
+					fmt.Sprintf("Process %s(%d) back to %.1f%% CPU", pr.Name, pr.PID, pr.CPUPercent))
+			}
+		}
+	}
 
 	// Collect keys to recover without holding lock while sending notifications
 	var toRecover []string
 	p.State.mu.Lock()
 	for key := range p.State.Alerts {
-		if strings.HasPrefix(key, agentURL+"|proc|") && !above[key] {
+		isProc := strings.HasPrefix(key, agentURL+"|proc|") || (strings.HasPrefix(key, agentURL+"|proccpu|") && !countersFailed)
+		if isProc && !above[key] {
 			delete(p.State.Alerts, key)
 			toRecover = append(toRecover, key)
 		}
@@ -202,9 +511,421 @@ func (p *Poller) checkAlerts(agentURL string, mtr *m.ServerMetrics) {
 	p.State.mu.Unlock()
 
 	for _, key := range toRecover {
-		log.Printf("[RECOVERED-SEND] %s | %s", "[RECOVERED] process below threshold", key)
-		_ = p.Notifier.Send("[RECOVERED] process below threshold", key)
+		if p.Repo != nil {
+			p.Repo.DeleteAlert(key)
+		}
+		// the key starts with the target URL, which may hold a password
+		masked := utils.MaskPassword(agentURL) + strings.TrimPrefix(key, agentURL)
+		log.Printf("[RECOVERED-SEND] %s | %s", "[RECOVERED] process below threshold", masked)
+		_ = p.Notifier.Send("[RECOVERED] process below threshold", masked)
+	}
+}
+
+// checkContainerAlerts applies container rules: resource limits, restarts since the previous
+// sample, and must-run containers. Alerts of containers that disappeared are recovered.
+func (p *Poller) checkContainerAlerts(agentURL string, prev, mtr *m.ServerMetrics) {
+	prevRestarts := map[string]int{}
+	if prev != nil {
+		for _, ct := range prev.Containers {
+			prevRestarts[ct.Name] = ct.RestartCount
+		}
+	}
+	seen := map[string]bool{}
+	for _, ct := range mtr.Containers {
+		seen[ct.Name] = true
+		rule := p.Cfg.ContainerRule(ct.Name)
+		if rule.CPUThreshold > 0 {
+			key := fmt.Sprintf("%s|container|%s|cpu", agentURL, ct.Name)
+			if ct.CPUPercent >= rule.CPUThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s container %s CPU high", mtr.Hostname, ct.Name),
+					fmt.Sprintf("Container %s uses %.1f%% CPU (threshold %.1f%%)", ct.Name, ct.CPUPercent, rule.CPUThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s container %s CPU", mtr.Hostname, ct.Name),
+					fmt.Sprintf("Container %s back to %.1f%% CPU", ct.Name, ct.CPUPercent))
+			}
+		}
+		// Without a memory limit the percentage is meaningless
+		if rule.MemThreshold > 0 && ct.MemoryLimit > 0 {
+			key := fmt.Sprintf("%s|container|%s|mem", agentURL, ct.Name)
+			if ct.MemoryPercent >= rule.MemThreshold {
+				p.raiseOnce(key, fmt.Sprintf("[ALERT] %s container %s memory high", mtr.Hostname, ct.Name),
+					fmt.Sprintf("Container %s uses %.1f%% of its memory limit (threshold %.1f%%)", ct.Name, ct.MemoryPercent, rule.MemThreshold))
+			} else {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s container %s memory", mtr.Hostname, ct.Name),
+					fmt.Sprintf("Container %s back to %.1f%% of its memory limit", ct.Name, ct.MemoryPercent))
+			}
+		}
+		key := fmt.Sprintf("%s|container|%s|restart", agentURL, ct.Name)
+		if before, ok := prevRestarts[ct.Name]; ok && ct.RestartCount > before {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s container %s restarted", mtr.Hostname, ct.Name),
+				fmt.Sprintf("Container %s restarted %d time(s) since last poll (total %d)", ct.Name, ct.RestartCount-before, ct.RestartCount))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s container %s restarts", mtr.Hostname, ct.Name),
+				fmt.Sprintf("Container %s stable at %d restart(s)", ct.Name, ct.RestartCount))
+		}
+	}
+
+	for name, rule := range p.Cfg.ContainerRules {
+		if !rule.MustRun {
+			continue
+		}
+		state := "missing"
+		for _, ct := range mtr.Containers {
+			if ct.Name == name {
+				state = ct.State
+				break
+			}
+		}
+		key := fmt.Sprintf("%s|container|%s|down", agentURL, name)
+		if state != "running" {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s container %s down", mtr.Hostname, name),
+				fmt.Sprintf("Container %s is %s", name, state))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s container %s", mtr.Hostname, name),
+				fmt.Sprintf("Container %s is running", name))
+		}
+	}
+
+	// Resource and restart alerts of removed containers can no longer recover on their own
+	for key, name := range p.staleAlerts(agentURL, "container", seen, "|down") {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s container %s", mtr.Hostname, name),
+			fmt.Sprintf("Container %s no longer present", name))
+	}
+}
+
+// checkUnitAlerts raises "unit failed" for watched systemd units that failed or are missing
+// and recovers them once active or cleanly stopped. Units dropped from the agent watch list
+// are recovered.
+func (p *Poller) checkUnitAlerts(agentURL string, mtr *m.ServerMetrics) {
+	seen := map[string]bool{}
+	for _, u := range mtr.SystemdUnits {
+		seen[u.Name] = true
+		key := fmt.Sprintf("%s|unit|%s", agentURL, u.Name)
+		if u.Failed() {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s unit %s failed", mtr.Hostname, u.Name),
+				fmt.Sprintf("Unit %s is %s/%s (load %s, restarts %d)", u.Name, u.ActiveState, u.SubState, u.LoadState, u.Restarts))
+		} else if u.ActiveState == "active" || u.ActiveState == "inactive" {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s unit %s", mtr.Hostname, u.Name),
+				fmt.Sprintf("Unit %s is %s/%s (restarts %d)", u.Name, u.ActiveState, u.SubState, u.Restarts))
+		}
+	}
+
+	for key, name := range p.staleAlerts(agentURL, "unit", seen) {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s unit %s", mtr.Hostname, name),
+			fmt.Sprintf("Unit %s is no longer watched", name))
+	}
+}
+
+// checkPluginAlerts raises WARNING and CRITICAL plugin check results. A change between the
+// two re-notifies with the new state; OK recovers. UNKNOWN leaves the alert state as it is.
+func (p *Poller) checkPluginAlerts(agentURL string, mtr *m.ServerMetrics) {
+	seen := map[string]bool{}
+	for _, ck := range mtr.Checks {
+		seen[ck.Name] = true
+		warnKey := fmt.Sprintf("%s|check|%s|warning", agentURL, ck.Name)
+		critKey := fmt.Sprintf("%s|check|%s|critical", agentURL, ck.Name)
+		body := fmt.Sprintf("Check %s is %s: %s", ck.Name, ck.Status, ck.Output)
+		switch ck.Status {
+		case m.CheckCritical:
+			p.clearAlert(warnKey)
+			p.raiseOnce(critKey, fmt.Sprintf("[ALERT] %s check %s CRITICAL", mtr.Hostname, ck.Name), body)
+		case m.CheckWarning:
+			p.clearAlert(critKey)
+			p.raiseOnce(warnKey, fmt.Sprintf("[ALERT] %s check %s WARNING", mtr.Hostname, ck.Name), body)
+		case m.CheckOK:
+			for _, key := range []string{warnKey, critKey} {
+				p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s check %s", mtr.Hostname, ck.Name), body)
+			}
+		}
+	}
+	for key, name := range p.staleAlerts(agentURL, "check", seen) {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s check %s", mtr.Hostname, name),
+			fmt.Sprintf("Check %s is no longer configured", name))
+	}
+}
+
+// checkPressureAlerts compares the 60 second PSI averages with PressureThresholds. Hosts
+// without PSI and CPU "full" on kernels that do not report it are skipped.
+func (p *Poller) checkPressureAlerts(agentURL string, mtr *m.ServerMetrics) {
+	if _, failed := mtr.CollectionErrors["pressure"]; failed {
+		return
+	}
+	for name, th := range p.Cfg.PressureThresholds {
+		resource, kind, _ := strings.Cut(name, "_")
+		avg, ok := mtr.Pressure.Resource(resource).Avg60(kind)
+		if th <= 0 || !ok {
+			continue
+		}
+		key := fmt.Sprintf("%s|psi|%s|%s", agentURL, resource, kind)
+		if avg >= th {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s %s pressure high", mtr.Hostname, resource),
+				fmt.Sprintf("Tasks stalled on %s (%s) %.1f%% of the last 60s (threshold %.1f%%)", resource, kind, avg, th))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s %s pressure", mtr.Hostname, resource),
+				fmt.Sprintf("Pressure on %s (%s) back to %.1f%%", resource, kind, avg))
+		}
+	}
+}
+
+// checkCollectorAlerts raises when a sub-collector failed in CollectorFailAlertAfter consecutive
+// samples, so a broken data source is not mistaken for a healthy zero
+func (p *Poller) checkCollectorAlerts(agentURL string, mtr *m.ServerMetrics) {
+	if p.Cfg.CollectorFailAlertAfter <= 0 {
+		return
+	}
+	prefix := agentURL + "|collector|"
+	p.failMu.Lock()
+	if p.collectorFails == nil {
+		p.collectorFails = map[string]int{}
+	}
+	fails := map[string]int{}
+	for name := range mtr.CollectionErrors {
+		key := prefix + name
+		p.collectorFails[key]++
+		fails[name] = p.collectorFails[key]
+	}
+	for key := range p.collectorFails {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			if _, failed := mtr.CollectionErrors[name]; !failed {
+				delete(p.collectorFails, key)
+			}
+		}
+	}
+	p.failMu.Unlock()
+
+	for name, n := range fails {
+		if n >= p.Cfg.CollectorFailAlertAfter {
+			p.raiseOnce(prefix+name, fmt.Sprintf("[ALERT] %s collector %s failing", mtr.Hostname, name),
+				fmt.Sprintf("Collector %s failed %d samples in a row: %s", name, n, mtr.CollectionErrors[name]))
+		}
+	}
+	failing := map[string]bool{}
+	for name := range fails {
+		failing[name] = true
+	}
+	for key, name := range p.staleAlerts(agentURL, "collector", failing) {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s collector %s", mtr.Hostname, name),
+			fmt.Sprintf("Collector %s is collecting again", name))
+	}
+}
+
+// checkProcessWatchAlerts raises "missing" when fewer matching processes run than required
+// and "restarted" when a matching process was replaced since the previous sample
+func (p *Poller) checkProcessWatchAlerts(agentURL string, prev, mtr *m.ServerMetrics) {
+	// Without a process list every watch would look missing
+	if _, failed := mtr.CollectionErrors["processes"]; failed {
+		return
+	}
+	prevWatches := map[string]m.ProcessWatch{}
+	if prev != nil {
+		for _, w := range prev.ProcessWatches {
+			prevWatches[w.Name] = w
+		}
+	}
+	seen := map[string]bool{}
+	for _, w := range mtr.ProcessWatches {
+		seen[w.Name] = true
+		key := fmt.Sprintf("%s|procwatch|%s|missing", agentURL, w.Name)
+		if w.Missing() {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s watched process %s missing", mtr.Hostname, w.Name),
+				fmt.Sprintf("Process watch %s matches %d process(es), at least %d required", w.Name, w.Count, w.MinCount))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s watched process %s", mtr.Hostname, w.Name),
+				fmt.Sprintf("Process watch %s matches %d process(es) %v", w.Name, w.Count, w.PIDs))
+		}
+		key = fmt.Sprintf("%s|procwatch|%s|restart", agentURL, w.Name)
+		if before, ok := prevWatches[w.Name]; ok && w.Restarted(before) {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s watched process %s restarted", mtr.Hostname, w.Name),
+				fmt.Sprintf("Process watch %s PIDs changed from %v to %v (youngest up %.0fs)", w.Name, before.PIDs, w.PIDs, w.UptimeSeconds))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s watched process %s restarts", mtr.Hostname, w.Name),
+				fmt.Sprintf("Process watch %s stable at %v", w.Name, w.PIDs))
+		}
+	}
+	for key, name := range p.staleAlerts(agentURL, "procwatch", seen) {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s watched process %s", mtr.Hostname, name),
+			fmt.Sprintf("Process watch %s is no longer configured", name))
+	}
+}
+
+// checkTCPAlerts raises when the socket count of a TCP state or the connections to one
+// remote endpoint exceed their limits
+func (p *Poller) checkTCPAlerts(agentURL string, mtr *m.ServerMetrics) {
+	if _, failed := mtr.CollectionErrors["tcp"]; failed {
+		return
+	}
+	for state, th := range p.Cfg.TCPStateThresholds {
+		if th <= 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s|tcp|state|%s", agentURL, state)
+		n := mtr.TCP.States[state]
+		if float64(n) >= th {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s TCP %s high", mtr.Hostname, state),
+				fmt.Sprintf("%d TCP sockets in %s (threshold %.0f)", n, state, th))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s TCP %s", mtr.Hostname, state),
+				fmt.Sprintf("%d TCP sockets in %s", n, state))
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, r := range mtr.TCP.TopRemotes {
+		ep := r.Endpoint()
+		th := p.Cfg.TCPRemoteLimit(ep)
+		if th <= 0 {
+			continue
+		}
+		seen[ep] = true
+		key := fmt.Sprintf("%s|tcp|remote|%s", agentURL, ep)
+		if float64(r.Count) >= th {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s TCP connections to %s high", mtr.Hostname, ep),
+				fmt.Sprintf("%d connections to %s, %d in CLOSE_WAIT (threshold %.0f)", r.Count, ep, r.CloseWait, th))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s TCP connections to %s", mtr.Hostname, ep),
+				fmt.Sprintf("%d connections to %s", r.Count, ep))
+		}
+	}
+	// An endpoint missing from a full list may still have as many connections as the
+	// least busy one reported, so its alert only recovers once that count is below its limit
+	bound := 0
+	if n := len(mtr.TCP.TopRemotes); n >= m.MaxTCPRemotes {
+		bound = mtr.TCP.TopRemotes[n-1].Count
+	}
+	for key, ep := range p.staleAlerts(agentURL, "tcp|remote", seen) {
+		if th := p.Cfg.TCPRemoteLimit(ep); th > 0 && float64(bound) >= th {
+			continue
+		}
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s TCP connections to %s", mtr.Hostname, ep),
+			fmt.Sprintf("Connections to %s are no longer among the busiest endpoints", ep))
+	}
+}
+
+// checkLogAlerts raises when a log watch matched more often than its threshold within its window
+func (p *Poller) checkLogAlerts(agentURL string, mtr *m.ServerMetrics) {
+	seen := map[string]bool{}
+	for _, lw := range mtr.LogWatches {
+		if lw.Threshold <= 0 {
+			continue
+		}
+		seen[lw.Name] = true
+		key := fmt.Sprintf("%s|log|%s", agentURL, lw.Name)
+		if lw.Matches > lw.Threshold {
+			p.raiseOnce(key, fmt.Sprintf("[ALERT] %s log %s matches high", mtr.Hostname, lw.Name), logWatchMessage(lw))
+		} else {
+			p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s log %s", mtr.Hostname, lw.Name), logWatchMessage(lw))
+		}
+	}
+	for key, name := range p.staleAlerts(agentURL, "log", seen) {
+		p.recoverIfActive(key, fmt.Sprintf("[RECOVERED] %s log %s", mtr.Hostname, name),
+			fmt.Sprintf("Log watch %s is no longer alerting", name))
+	}
+}
+
+func logWatchMessage(lw m.LogWatch) string {
+	msg := fmt.Sprintf("Log watch %s matched %d times in %ds (threshold %d, pattern %q)",
+		lw.Name, lw.Matches, lw.WindowSeconds, lw.Threshold, lw.Pattern)
+	if n := len(lw.LastLines); n > 0 {
+		msg += fmt.Sprintf("; last: %s: %s", lw.LastLines[n-1].File, lw.LastLines[n-1].Text)
+	}
+	return msg
+}
+
+// staleAlerts returns the active alerts of one type whose object (the key part after the
+// type, e.g. the container name) is not in present, mapped to that object name.
+// Keys ending in one of the skip suffixes are left alone.
+func (p *Poller) staleAlerts(agentURL, typ string, present map[string]bool, skip ...string) map[string]string {
+	prefix := agentURL + "|" + typ + "|"
+	stale := map[string]string{}
+	p.State.mu.RLock()
+	defer p.State.mu.RUnlock()
+	for key := range p.State.Alerts {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		skipped := false
+		for _, sfx := range skip {
+			skipped = skipped || strings.HasSuffix(key, sfx)
+		}
+		name, _, _ := strings.Cut(strings.TrimPrefix(key, prefix), "|")
+		if !skipped && !present[name] {
+			stale[key] = name
+		}
+	}
+	return stale
+}
+
+// clearAlert drops an active alert without notifying, used when an alert is replaced
+// by one of a different severity for the same object
+func (p *Poller) clearAlert(key string) {
+	p.State.mu.Lock()
+	active := p.State.Alerts[key]
+	delete(p.State.Alerts, key)
+	p.State.mu.Unlock()
+	if active && p.Repo != nil {
+		p.Repo.DeleteAlert(key)
+	}
+}
+
+// checkEvents compares a sample with the previous one of the same target and emits
+// reboots and OOM kills. The OOM kill counter restarts at boot, so it is only compared
+// between samples of the same boot.
+func (p *Poller) checkEvents(agentURL string, prev, mtr *m.ServerMetrics) {
+	if prev == nil {
+		return
+	}
+	if mtr.UptimeSeconds > 0 && mtr.UptimeSeconds < prev.UptimeSeconds {
+		p.emitEvent(agentURL, mtr.Hostname, m.EventReboot, fmt.Sprintf("[EVENT] %s rebooted", mtr.Hostname),
+			fmt.Sprintf("Uptime went back from %s to %s", formatUptime(prev.UptimeSeconds), formatUptime(mtr.UptimeSeconds)))
+		return
+	}
+	// a failed sample reads 0 kills, the next complete one must not count them all as new
+	// (SSH targets read /proc/vmstat with the counter sample)
+	complete := func(s *m.ServerMetrics) bool {
+		_, memFailed := s.CollectionErrors["memory"]
+		_, countersFailed := s.CollectionErrors["counters"]
+		return !memFailed && !countersFailed
+	}
+	if complete(mtr) && complete(prev) && mtr.Memory.OOMKills > prev.Memory.OOMKills {
+		n := mtr.Memory.OOMKills - prev.Memory.OOMKills
+		p.emitEvent(agentURL, mtr.Hostname, m.EventOOMKill, fmt.Sprintf("[EVENT] %s OOM kill", mtr.Hostname),
+			fmt.Sprintf("Kernel OOM killer killed %d process(es) since the last poll, %d since boot", n, mtr.Memory.OOMKills))
+	}
+}
+
+// formatUptime renders seconds as e.g. "3d4h5m" or "42s"
+func formatUptime(sec uint64) string {
+	d := time.Duration(sec) * time.Second
+	if d < time.Minute {
+		return d.String()
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	s := fmt.Sprintf("%dh%dm", d/time.Hour, (d%time.Hour)/time.Minute)
+	if days > 0 {
+		s = fmt.Sprintf("%dd", days) + s
+	}
+	return s
+}
+
+// emitEvent records a one-shot event and sends it to the notifier and WebSocket clients
+func (p *Poller) emitEvent(agentURL, hostname, typ, subject, body string) {
+	e := m.Event{
+		ServerURL:  agentURL,
+		Hostname:   hostname,
+		Type:       typ,
+		Subject:    subject,
+		Message:    body,
+		OccurredAt: time.Now().UTC(),
+	}
+	if p.Repo != nil {
+		p.Repo.AddEvent(e)
+	}
+	if p.WSHub != nil {
+		p.WSHub.BroadcastEvent(e)
 	}
+	log.Printf("[EVENT-SEND] %s | %s", subject, body)
+	_ = p.Notifier.Send(subject, body)
 }
 
 func (p *Poller) raiseOnce(key, subject, body string) {
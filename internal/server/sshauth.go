@@ -0,0 +1,302 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key checking modes of SSH targets
+const (
+	hostKeyStrict = "strict" // unknown and changed host keys are rejected
+	hostKeyTOFU   = "tofu"   // unknown host keys are trusted and added to known_hosts, changed ones rejected
+	hostKeyOff    = "off"    // any host key is accepted
+)
+
+// SSHTarget holds the credentials and host key policy of SSH targets, as read from the SSH
+// targets file. Entries are matched by host and, unless 0, port:
+//
+//	[{"host": "192.168.4.3", "port": 2222, "user": "scada", "keyFile": "/etc/monserv/id_ed25519",
+//	  "keyPassphrase": "secret", "knownHosts": "/etc/monserv/known_hosts", "hostKeyCheck": "tofu"},
+//	 {"host": "192.168.4.4", "useAgent": true, "hostKeyCheck": "strict"},
+//	 {"host": "10.20.0.7", "proxyJump": "ops@bastion.example.com,ops@10.20.0.1:2222"}]
+//
+// Empty fields fall back to the SSH_* defaults. A password in the target URL is still
+// offered after the keys. ProxyJump lists the jump hosts to connect through, in order;
+// the hops are configured by their own entries, whose proxyJump is not followed.
+type SSHTarget struct {
+	Host          string `json:"host"`
+	Port          int    `json:"port"`
+	User          string `json:"user"`
+	KeyFile       string `json:"keyFile"`
+	KeyPassphrase string `json:"keyPassphrase"`
+	UseAgent      *bool  `json:"useAgent"`
+	AgentSocket   string `json:"agentSocket"`
+	KnownHosts    string `json:"knownHosts"`
+	HostKeyCheck  string `json:"hostKeyCheck"`
+	ProxyJump     string `json:"proxyJump"`
+}
+
+// LoadSSHTargets reads per-target SSH settings from a JSON file
+func LoadSSHTargets(path string) ([]SSHTarget, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var targets []SSHTarget
+	if err := json.Unmarshal(b, &targets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	seen := map[string]bool{}
+	for i, t := range targets {
+		if t.Host == "" {
+			return nil, fmt.Errorf("%s: SSH target %d needs a host", path, i+1)
+		}
+		id := net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+		if seen[id] {
+			return nil, fmt.Errorf("%s: duplicate SSH target %s", path, id)
+		}
+		seen[id] = true
+		switch t.HostKeyCheck {
+		case "", hostKeyStrict, hostKeyTOFU, hostKeyOff:
+		default:
+			return nil, fmt.Errorf("%s: SSH target %s: hostKeyCheck must be strict, tofu or off", path, t.Host)
+		}
+		if _, err := parseProxyJump(t.ProxyJump); err != nil {
+			return nil, fmt.Errorf("%s: SSH target %s: %w", path, t.Host, err)
+		}
+	}
+	return targets, nil
+}
+
+// withDefaults fills the empty fields of t from def and settles the host key mode:
+// strict when a known_hosts file is set, off otherwise, which hostKeyCallback warns about
+func (t SSHTarget) withDefaults(def SSHTarget) SSHTarget {
+	t.User = firstSet(t.User, def.User)
+	if t.KeyFile == "" {
+		t.KeyFile, t.KeyPassphrase = def.KeyFile, def.KeyPassphrase
+	}
+	if t.UseAgent == nil {
+		t.UseAgent = def.UseAgent
+	}
+	t.AgentSocket = firstSet(t.AgentSocket, def.AgentSocket)
+	t.KnownHosts = firstSet(t.KnownHosts, def.KnownHosts)
+	t.HostKeyCheck = firstSet(t.HostKeyCheck, def.HostKeyCheck)
+	t.ProxyJump = firstSet(t.ProxyJump, def.ProxyJump)
+	if t.HostKeyCheck == "" {
+		t.HostKeyCheck = hostKeyOff
+		if t.KnownHosts != "" {
+			t.HostKeyCheck = hostKeyStrict
+		}
+	}
+	return t
+}
+
+// SSHTargetFor returns the SSH settings of a target, the matching SSH targets file entry
+// merged over the defaults
+func (c Config) SSHTargetFor(host string, port int) SSHTarget {
+	for _, t := range c.SSHTargets {
+		if t.Host == host && (t.Port == 0 || t.Port == port) {
+			return t.withDefaults(c.SSHDefaults)
+		}
+	}
+	return c.SSHDefaults.withDefaults(SSHTarget{})
+}
+
+// clientConfig builds the client configuration for addr ("host:port"): key file and
+// ssh-agent keys are offered first, then the password, if any. The returned function
+// releases the agent connection once the handshake is done.
+func (t SSHTarget) clientConfig(addr, user, pass string) (*ssh.ClientConfig, func(), error) {
+	release := func() {}
+	var signers []ssh.Signer
+	if t.KeyFile != "" {
+		s, err := loadSigner(t.KeyFile, t.KeyPassphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+		signers = append(signers, s)
+	}
+	var agentSigners func() ([]ssh.Signer, error)
+	if t.UseAgent != nil && *t.UseAgent {
+		sock := firstSet(t.AgentSocket, os.Getenv("SSH_AUTH_SOCK"))
+		if sock == "" {
+			return nil, nil, errors.New("ssh-agent requested but no agent socket is set (agentSocket or SSH_AUTH_SOCK)")
+		}
+		conn, err := net.DialTimeout("unix", sock, 5*time.Second)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		release = func() { conn.Close() }
+		agentSigners = agent.NewClient(conn).Signers
+	}
+
+	// The client tries each auth method once, so every key goes into one publickey method
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 || agentSigners != nil {
+		auth = append(auth, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			if agentSigners == nil {
+				return signers, nil
+			}
+			fromAgent, err := agentSigners()
+			if err != nil {
+				log.Printf("[SSH] ssh-agent: %v", err)
+			}
+			return append(append([]ssh.Signer(nil), signers...), fromAgent...), nil
+		}))
+	}
+	if pass != "" {
+		auth = append(auth, ssh.Password(pass))
+	}
+	if len(auth) == 0 {
+		release()
+		return nil, nil, errors.New("no SSH credentials: set a key file, ssh-agent or a password in the target URL")
+	}
+
+	hostKeyCallback, algorithms, err := t.hostKeyCallback(addr)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return &ssh.ClientConfig{
+		User:              user,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: algorithms,
+		Timeout:           5 * time.Second,
+	}, release, nil
+}
+
+// loadSigner reads a private key file, decrypting it with the passphrase when needed
+func loadSigner(path, passphrase string) (ssh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(b)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("%s: key is encrypted and no passphrase is set", path)
+		}
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(b, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// knownHostsMu serialises known_hosts updates of concurrent TOFU connections
+var knownHostsMu sync.Mutex
+
+// hostKeyOffWarned holds the addresses already warned about unchecked host keys, so
+// that reconnects do not repeat the warning
+var hostKeyOffWarned sync.Map
+
+// hostKeyCallback verifies host keys against the known_hosts file. When the file already
+// knows the host, the handshake is restricted to its key algorithms, otherwise the server
+// could present a key of another type and fail verification.
+func (t SSHTarget) hostKeyCallback(addr string) (ssh.HostKeyCallback, []string, error) {
+	switch t.HostKeyCheck {
+	case hostKeyOff:
+		if _, warned := hostKeyOffWarned.LoadOrStore(addr, true); !warned {
+			log.Printf("[SSH] %s: host key checking is off, any host key is accepted; set SSH_KNOWN_HOSTS or hostKeyCheck to verify it", addr)
+		}
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	case hostKeyStrict, hostKeyTOFU:
+	default:
+		return nil, nil, fmt.Errorf("unknown host key check mode %q", t.HostKeyCheck)
+	}
+	if t.KnownHosts == "" {
+		return nil, nil, fmt.Errorf("host key check %q needs a known_hosts file", t.HostKeyCheck)
+	}
+	if t.HostKeyCheck == hostKeyTOFU {
+		// the first connection creates the file
+		f, err := os.OpenFile(t.KnownHosts, os.O_CREATE|os.O_RDONLY, 0o600)
+		if err != nil {
+			return nil, nil, err
+		}
+		f.Close()
+	}
+	check, err := knownhosts.New(t.KnownHosts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callback := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var ke *knownhosts.KeyError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &ke) && len(ke.Want) > 0:
+			return fmt.Errorf("host key of %s changed to %s %s, remove the old entry from %s if this is expected: %w",
+				hostname, key.Type(), ssh.FingerprintSHA256(key), t.KnownHosts, err)
+		case errors.As(err, &ke) && t.HostKeyCheck == hostKeyTOFU:
+			return trustHostKey(t.KnownHosts, hostname, remote, key)
+		case errors.As(err, &ke):
+			return fmt.Errorf("host key of %s (%s %s) is not in %s", hostname, key.Type(), ssh.FingerprintSHA256(key), t.KnownHosts)
+		}
+		return err
+	}
+	return callback, knownHostAlgorithms(check, addr), nil
+}
+
+// trustHostKey adds the key of an unknown host to known_hosts. The file is checked again
+// under the lock, since another connection to the same host may just have added it.
+func trustHostKey(path, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+	if check, err := knownhosts.New(path); err == nil {
+		var ke *knownhosts.KeyError
+		if err := check(hostname, remote, key); err == nil || !errors.As(err, &ke) || len(ke.Want) > 0 {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, knownhosts.Line([]string{hostname}, key)); err != nil {
+		return err
+	}
+	log.Printf("[SSH] trusted new host key of %s: %s %s", hostname, key.Type(), ssh.FingerprintSHA256(key))
+	return nil
+}
+
+// knownHostAlgorithms lists the host key algorithms known_hosts holds for addr; nil when
+// the host is unknown. The lookup presents a key that never matches and reads the keys
+// the mismatch error reports.
+func knownHostAlgorithms(check ssh.HostKeyCallback, addr string) []string {
+	probe, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+	var ke *knownhosts.KeyError
+	if err := check(addr, &net.TCPAddr{}, probe); !errors.As(err, &ke) {
+		return nil
+	}
+	var algorithms []string
+	for _, k := range ke.Want {
+		switch typ := k.Key.Type(); typ {
+		case ssh.KeyAlgoRSA:
+			// RSA keys are used with SHA-2 signatures by current servers
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, typ)
+		}
+	}
+	return algorithms
+}
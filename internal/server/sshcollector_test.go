@@ -0,0 +1,460 @@
+package server
+
+import (
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	m "monserv/internal/metrics"
+)
+
+// readCollectOutput returns a captured output of the collection script from testdata
+func readCollectOutput(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// cutBefore truncates out at the first line starting with marker, as a script killed there would
+func cutBefore(t *testing.T, out, marker string) string {
+	t.Helper()
+	i := strings.Index(out, "\n"+marker)
+	if i < 0 {
+		t.Fatalf("marker %q not found", marker)
+	}
+	return out[:i+1]
+}
+
+// longSysfsLine replaces the first line of the sysfs section of out with one over the
+// 1 MiB line limit of parseCollectOutput
+func longSysfsLine(t *testing.T, out string) string {
+	t.Helper()
+	const line = "/sys/class/hwmon/hwmon0/name:coretemp\n"
+	if !strings.Contains(out, line) {
+		t.Fatalf("line %q not found", line)
+	}
+	return strings.Replace(out, line, "/sys/class/hwmon/hwmon0/name:"+strings.Repeat("x", 2<<20)+"\n", 1)
+}
+
+func TestParseCollectOutput(t *testing.T) {
+	gnu := readCollectOutput(t, "collect_gnu.txt")
+	tests := []struct {
+		name       string
+		out        string
+		wantPrev   bool
+		wantStatus map[string]int
+		wantCur    map[string]string
+		wantErr    bool
+	}{
+		{
+			name:     "complete",
+			out:      gnu,
+			wantPrev: true,
+			wantStatus: map[string]int{
+				"hostname": 0, "host": 0, "cpuinfo": 0, "/proc/loadavg": 0, "/proc/meminfo": 0, "/proc/mounts": 0,
+				"df": 1, "dfi": 1, "sysfs": 2, "pagesize": 0, "ps": 0, "tcp": 0, "sockets": 0,
+			},
+			wantCur: map[string]string{
+				"/proc/uptime": "1002.00 1903.00\n",
+				"hostname":     "web-1\n",
+				"pagesize":     "4096\n",
+			},
+		},
+		{
+			name:       "cut before the second sample",
+			out:        cutBefore(t, gnu, sampleSeparator),
+			wantPrev:   false,
+			wantStatus: map[string]int{},
+			wantCur:    map[string]string{"/proc/uptime": "1000.00 1900.00\n"},
+		},
+		{
+			name:     "cut inside a section",
+			out:      cutBefore(t, gnu, "/dev/sdb1        102400000"),
+			wantPrev: true,
+			wantStatus: map[string]int{
+				"hostname": 0, "host": 0, "cpuinfo": 0, "/proc/loadavg": 0, "/proc/meminfo": 0, "/proc/mounts": 0,
+			},
+			wantCur: map[string]string{
+				"df": "Filesystem     1024-blocks     Used Available Capacity Mounted on\n/dev/sda1         41152736  8930276  30109032      23% /\n",
+			},
+		},
+		{
+			name:     "line over the limit",
+			out:      longSysfsLine(t, gnu),
+			wantPrev: true,
+			wantStatus: map[string]int{
+				"hostname": 0, "host": 0, "cpuinfo": 0, "/proc/loadavg": 0, "/proc/meminfo": 0, "/proc/mounts": 0,
+				"df": 1, "dfi": 1,
+			},
+			wantCur: map[string]string{"hostname": "web-1\n", "sysfs": "", "pagesize": ""},
+			wantErr: true,
+		},
+		{
+			name:       "empty",
+			out:        "",
+			wantPrev:   false,
+			wantStatus: map[string]int{},
+			wantCur:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev, cur, status, err := parseCollectOutput(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if (prev != nil) != tt.wantPrev {
+				t.Errorf("prev = %v, want present %v", prev != nil, tt.wantPrev)
+			}
+			if !reflect.DeepEqual(status, tt.wantStatus) {
+				t.Errorf("status = %v, want %v", status, tt.wantStatus)
+			}
+			for name, want := range tt.wantCur {
+				if cur[name] != want {
+					t.Errorf("cur[%q] = %q, want %q", name, cur[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name      string
+		out       string
+		wantTotal m.CPUTimes
+		wantCores int
+	}{
+		{
+			name:      "current kernel",
+			out:       "cpu  1000 5 500 8000 100 10 20 30 0 0\ncpu0 500 5 250 4000 50 5 10 15 0 0\ncpu1 500 0 250 4000 50 5 10 15 0 0\nintr 123456 0 0\nbtime 1700000000\n",
+			wantTotal: m.CPUTimes{User: 1000, Nice: 5, System: 500, Idle: 8000, IOWait: 100, IRQ: 10, SoftIRQ: 20, Steal: 30},
+			wantCores: 2,
+		},
+		{
+			name:      "2.6 kernel without steal",
+			out:       "cpu  2000 0 1000 16000 0 0 0\ncpu0 2000 0 1000 16000 0 0 0\n",
+			wantTotal: m.CPUTimes{User: 2000, System: 1000, Idle: 16000},
+			wantCores: 1,
+		},
+		{
+			name:      "truncated core line",
+			out:       "cpu  1000 0 500 8000 100 0 0 0\ncpu0 500 0\n",
+			wantTotal: m.CPUTimes{User: 1000, System: 500, Idle: 8000, IOWait: 100},
+			wantCores: 0,
+		},
+		{
+			name: "empty",
+			out:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, cores := parseProcStat(tt.out)
+			if total != tt.wantTotal {
+				t.Errorf("total = %+v, want %+v", total, tt.wantTotal)
+			}
+			if len(cores) != tt.wantCores {
+				t.Errorf("cores = %d, want %d", len(cores), tt.wantCores)
+			}
+		})
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want m.Memory
+	}{
+		{
+			name: "MemAvailable",
+			out:  "MemTotal:        4000000 kB\nMemFree:          500000 kB\nMemAvailable:    3000000 kB\nBuffers:          100000 kB\nCached:          2000000 kB\nShmem:             10000 kB\nSwapTotal:       2000000 kB\nSwapFree:        1500000 kB\nHugePages_Total:       8\nHugePages_Free:        2\nHugepagesize:       2048 kB\n",
+			want: m.Memory{
+				Total: 4096000000, Free: 3072000000, Used: 1024000000, UsedPercent: 25,
+				Buffers: 102400000, Cached: 2048000000, Shared: 10240000,
+				HugePagesTotal: 8, HugePagesFree: 2, HugePageSize: 2097152,
+				SwapTotal: 2048000000, SwapFree: 1536000000, SwapUsed: 512000000, SwapUsedPercent: 25,
+			},
+		},
+		{
+			name: "old kernel without MemAvailable",
+			out:  "MemTotal:        1000 kB\nMemFree:          200 kB\nBuffers:          100 kB\nCached:           200 kB\nSwapTotal:          0 kB\nSwapFree:           0 kB\n",
+			want: m.Memory{
+				Total: 1024000, Free: 512000, Used: 512000, UsedPercent: 50,
+				Buffers: 102400, Cached: 204800,
+			},
+		},
+		{
+			name: "truncated",
+			out:  "MemTotal:        4000000 kB\nMemFree:",
+			want: m.Memory{Total: 4096000000, Used: 4096000000, UsedPercent: 100},
+		},
+		{
+			name: "empty",
+			out:  "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseMeminfo(tt.out); got != tt.want {
+				t.Errorf("parseMeminfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVmstatSwap(t *testing.T) {
+	const vmstat = "nr_free_pages 12345\npswpin 100\npswpout 200\noom_kill 1\n"
+	tests := []struct {
+		name     string
+		out      string
+		pageSize uint64
+		wantIn   uint64
+		wantOut  uint64
+	}{
+		{name: "4 KiB pages", out: vmstat, pageSize: 4096, wantIn: 409600, wantOut: 819200},
+		{name: "64 KiB pages", out: vmstat, pageSize: 65536, wantIn: 6553600, wantOut: 13107200},
+		{name: "no swap counters", out: "nr_free_pages 12345\n", pageSize: 4096},
+		{name: "empty", out: "", pageSize: 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := parseVmstatSwap(tt.out, tt.pageSize)
+			if in != tt.wantIn || out != tt.wantOut {
+				t.Errorf("parseVmstatSwap() = %d, %d, want %d, %d", in, out, tt.wantIn, tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestParseDf(t *testing.T) {
+	fstypes := map[string]string{"/": "ext4", "/mnt/my data": "xfs", "/dev": "devtmpfs"}
+	tests := []struct {
+		name string
+		out  string
+		want []m.DiskPartition
+	}{
+		{
+			name: "coreutils",
+			out:  "Filesystem     1024-blocks     Used Available Capacity Mounted on\n/dev/sda1         41152736  8930276  30109032      23% /\n/dev/sdb1        102400000 51200000  51200000      50% /mnt/my data\ndf: /mnt/nfs: Stale file handle\n",
+			want: []m.DiskPartition{
+				{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Total: 42140401664, Used: 9144602624, Free: 30831648768, UsedPercent: 23},
+				{Device: "/dev/sdb1", Mountpoint: "/mnt/my data", Fstype: "xfs", Total: 104857600000, Used: 52428800000, Free: 52428800000, UsedPercent: 50},
+			},
+		},
+		{
+			name: "busybox",
+			out:  "Filesystem           1024-blocks    Used Available Capacity Mounted on\n/dev/root             30450032   2561808  26617264   9% /\ndevtmpfs                 465040         0    465040   0% /dev\n",
+			want: []m.DiskPartition{
+				{Device: "/dev/root", Mountpoint: "/", Fstype: "ext4", Total: 31180832768, Used: 2623291392, Free: 27256078336, UsedPercent: 9},
+				{Device: "devtmpfs", Mountpoint: "/dev", Fstype: "devtmpfs", Total: 476200960, Free: 476200960},
+			},
+		},
+		{
+			name: "truncated row",
+			out:  "Filesystem     1024-blocks     Used Available Capacity Mounted on\n/dev/sda1         41152736  8930276  30109032      23% /\n/dev/sdb1        102400000 512",
+			want: []m.DiskPartition{
+				{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Total: 42140401664, Used: 9144602624, Free: 30831648768, UsedPercent: 23},
+			},
+		},
+		{
+			name: "error only",
+			out:  "df: /mnt/nfs: Stale file handle\n",
+			want: []m.DiskPartition{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDf(tt.out, fstypes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProcPidStat(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want map[int32]procStat
+	}{
+		{
+			name: "plain and odd process names",
+			out: "1 (systemd) S 0 1 1 0 -1 4194560 1000 0 0 0 100 50 0 0 20 0 1 0 10 170000000 3000 18446744073709551615 1 1 0 0 0 0 0 4096 1260 0 0 0 17 0 0 0 0 0 0\n" +
+				"812 (node (worker)) R 1 812 812 0 -1 4194560 1000 0 0 0 1000 100 0 0 20 0 1 0 50000 170000000 25000 18446744073709551615 1 1 0 0 0 0 0 4096 1260 0 0 0 17 0 0 0 0 0 0\n" +
+				"913 (tmux: server) S 1 913 913 0 -1 4194560 1000 0 0 0 7 3 0 0 20 0 1 0 70000 170000000 900 18446744073709551615 1 1 0 0 0 0 0 4096 1260 0 0 0 17 0 0 0 0 0 0\n",
+			want: map[int32]procStat{
+				1:   {name: "systemd", ticks: 150, startTicks: 10, rssPages: 3000},
+				812: {name: "node (worker)", ticks: 1100, startTicks: 50000, rssPages: 25000},
+				913: {name: "tmux: server", ticks: 10, startTicks: 70000, rssPages: 900},
+			},
+		},
+		{
+			name: "process exited between glob and read",
+			out:  "1 (systemd) S 0 1 1 0 -1 4194560 1000 0 0 0 100 50 0 0 20 0 1 0 10 170000000 3000 18446744073709551615 1 1 0 0 0 0 0 4096 1260 0 0 0 17 0 0 0 0 0 0\ncat: can't open '/proc/4242/stat': No such file or directory\n",
+			want: map[int32]procStat{
+				1: {name: "systemd", ticks: 150, startTicks: 10, rssPages: 3000},
+			},
+		},
+		{
+			name: "truncated line",
+			out:  "1 (systemd) S 0 1 1 0 -1 4194560 1000 0 0 0 100 50 0 0 20 0 1 0 10 170000000 3000 18446744073709551615 1 1 0 0 0 0 0 4096 1260 0 0 0 17 0 0 0 0 0 0\n812 (node) R 1 812 812 0 -1 4194560 1000 0 0 0 1000 100 0 0",
+			want: map[int32]procStat{
+				1: {name: "systemd", ticks: 150, startTicks: 10, rssPages: 3000},
+			},
+		},
+		{
+			name: "empty",
+			out:  "",
+			want: map[int32]procStat{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseProcPidStat(tt.out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcPidStat() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsFromScript(t *testing.T) {
+	gnu := readCollectOutput(t, "collect_gnu.txt")
+	busybox := readCollectOutput(t, "collect_busybox.txt")
+	tests := []struct {
+		name         string
+		out          string
+		wantHostname string
+		wantCores    int
+		wantCPU      float64
+		wantMemory   uint64
+		wantDisks    []string
+		wantRecv     map[string]float64 // network receive rate per interface
+		wantTopCPU   []m.ProcCPU
+		wantErrs     []string
+	}{
+		{
+			// the samples are 2s apart by /proc/uptime, not the 1s slept
+			name:         "procps and coreutils",
+			out:          gnu,
+			wantHostname: "web-1",
+			wantCores:    2,
+			wantCPU:      60,
+			wantMemory:   4096000000,
+			wantDisks:    []string{"/", "/mnt/my data"},
+			wantRecv:     map[string]float64{"lo": 0, "eth0": 1000},
+			wantTopCPU: []m.ProcCPU{
+				{PID: 812, Name: "node (worker)", Username: "app", CPUPercent: 100, RSSBytes: 102400000, Cmdline: "node /srv/app/server.js --port 3000"},
+				{PID: 900, Name: "postgres", Username: "postgres", CPUPercent: 25, RSSBytes: 204800000, Cmdline: "postgres: 14/main: checkpointer"},
+			},
+		},
+		{
+			name:         "busybox",
+			out:          busybox,
+			wantHostname: "gateway",
+			wantCores:    4,
+			wantCPU:      5,
+			wantMemory:   971063296,
+			wantDisks:    []string{"/", "/dev", "/run"},
+			wantRecv:     map[string]float64{"lo": 0, "wlan0": 1000},
+			wantTopCPU: []m.ProcCPU{
+				{PID: 410, Name: "mosquitto", Username: "mosquitt", CPUPercent: 15, RSSBytes: 2457600, Cmdline: "/usr/sbin/mosquitto -c /etc/mosquitto/mosquitto.conf"},
+			},
+		},
+		{
+			name:       "cut before the second sample",
+			out:        cutBefore(t, gnu, sampleSeparator),
+			wantCores:  2,
+			wantRecv:   map[string]float64{"lo": 0, "eth0": 0},
+			wantTopCPU: []m.ProcCPU{},
+			wantErrs:   []string{"counters", "cpu", "disk", "diskio", "host", "load", "memory", "network", "processes", "tcp"},
+		},
+		{
+			name:         "cut inside df",
+			out:          cutBefore(t, gnu, "/dev/sdb1        102400000"),
+			wantHostname: "web-1",
+			wantCores:    2,
+			wantCPU:      60,
+			wantMemory:   4096000000,
+			wantRecv:     map[string]float64{"lo": 0, "eth0": 1000},
+			wantTopCPU: []m.ProcCPU{
+				{PID: 812, Name: "node (worker)", CPUPercent: 100, RSSBytes: 102400000},
+				{PID: 900, Name: "postgres", CPUPercent: 25, RSSBytes: 204800000},
+			},
+			wantErrs: []string{"disk", "processes", "tcp"},
+		},
+		{
+			name:         "line over the limit",
+			out:          longSysfsLine(t, gnu),
+			wantHostname: "web-1",
+			wantCores:    2,
+			wantCPU:      60,
+			wantMemory:   4096000000,
+			wantDisks:    []string{"/", "/mnt/my data"},
+			wantRecv:     map[string]float64{"lo": 0, "eth0": 1000},
+			wantTopCPU: []m.ProcCPU{
+				{PID: 812, Name: "node (worker)", CPUPercent: 100, RSSBytes: 102400000},
+				{PID: 900, Name: "postgres", CPUPercent: 25, RSSBytes: 204800000},
+			},
+			wantErrs: []string{"processes", "script", "tcp"},
+		},
+		{
+			name:       "empty",
+			out:        "",
+			wantCores:  1,
+			wantRecv:   map[string]float64{},
+			wantTopCPU: []m.ProcCPU{},
+			wantErrs:   []string{"counters", "cpu", "disk", "diskio", "host", "load", "memory", "network", "processes", "tcp"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			met := metricsFromScript(tt.out, nil)
+			if met.Hostname != tt.wantHostname {
+				t.Errorf("Hostname = %q, want %q", met.Hostname, tt.wantHostname)
+			}
+			if met.CPU.Cores != tt.wantCores {
+				t.Errorf("CPU.Cores = %d, want %d", met.CPU.Cores, tt.wantCores)
+			}
+			if met.CPU.UsedPercent != tt.wantCPU {
+				t.Errorf("CPU.UsedPercent = %v, want %v", met.CPU.UsedPercent, tt.wantCPU)
+			}
+			if met.Memory.Total != tt.wantMemory {
+				t.Errorf("Memory.Total = %d, want %d", met.Memory.Total, tt.wantMemory)
+			}
+			var mounts []string
+			for _, d := range met.Disks {
+				mounts = append(mounts, d.Mountpoint)
+			}
+			if !reflect.DeepEqual(mounts, tt.wantDisks) {
+				t.Errorf("disk mounts = %q, want %q", mounts, tt.wantDisks)
+			}
+			recv := map[string]float64{}
+			for _, ni := range met.Network {
+				recv[ni.Name] = ni.BytesRecvPerSec
+			}
+			if !reflect.DeepEqual(recv, tt.wantRecv) {
+				t.Errorf("receive rates = %v, want %v", recv, tt.wantRecv)
+			}
+			// processes idle over the sample window tie at 0% in no particular order
+			top := met.TopProcsByCPU[:min(len(tt.wantTopCPU), len(met.TopProcsByCPU))]
+			if !reflect.DeepEqual(top, tt.wantTopCPU) {
+				t.Errorf("TopProcsByCPU = %+v, want %+v", top, tt.wantTopCPU)
+			}
+			var errs []string
+			for name := range met.CollectionErrors {
+				errs = append(errs, name)
+			}
+			slices.Sort(errs)
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("CollectionErrors = %v, want failures of %v", met.CollectionErrors, tt.wantErrs)
+			}
+		})
+	}
+}
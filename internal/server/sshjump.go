@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"monserv/internal/utils"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpHost is one hop of a ProxyJump chain
+type jumpHost struct {
+	user string
+	pass string
+	host string
+	port int
+}
+
+// parseProxyJump parses a ProxyJump list: "[user[:pass]@]host[:port]" hops separated by
+// commas, connected in order, optionally written as ssh:// URLs. "none" and "" mean a
+// direct connection. Errors quote hops with their password masked.
+func parseProxyJump(s string) ([]jumpHost, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "none" {
+		return nil, nil
+	}
+	var hops []jumpHost
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		raw := spec
+		if !strings.HasPrefix(raw, "ssh://") {
+			raw = "ssh://" + raw
+		}
+		// url.Parse errors quote the input, password included
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "ssh" || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid jump host %q", utils.MaskPassword(raw))
+		}
+		port := 22
+		if p := u.Port(); p != "" {
+			if port, err = strconv.Atoi(p); err != nil || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("invalid port in jump host %q", utils.MaskPassword(raw))
+			}
+		}
+		pass, _ := u.User.Password()
+		hops = append(hops, jumpHost{user: u.User.Username(), pass: pass, host: u.Hostname(), port: port})
+	}
+	return hops, nil
+}
+
+// jumpTo connects through the jump hosts in order, each hop tunnelled through the previous
+// one, and returns the last. Hop credentials and host key checking come from the hop's own
+// SSH settings, the user falling back to targetUser. The connections are pooled, so every
+// target behind the same chain shares them.
+func (c Config) jumpTo(pool *SSHPool, hops []jumpHost, targetUser string) (*SSHConn, error) {
+	var via *SSHConn
+	var key strings.Builder
+	for _, h := range hops {
+		auth := c.SSHTargetFor(h.host, h.port)
+		user := firstSet(h.user, firstSet(auth.User, targetUser))
+		addr := net.JoinHostPort(h.host, strconv.Itoa(h.port))
+		// the key holds the password so that hops with other credentials are not shared
+		fmt.Fprintf(&key, "%s:%s@%s,", user, h.pass, addr)
+		prev := via
+		conn, err := pool.Get("jump:"+key.String(), user, addr, func() (*ssh.Client, error) {
+			clientCfg, release, err := auth.clientConfig(addr, user, h.pass)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+			if prev != nil {
+				return prev.dial(addr, clientCfg)
+			}
+			return ssh.Dial("tcp", addr, clientCfg)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("jump host %s@%s: %w", user, addr, err)
+		}
+		via = conn
+	}
+	return via, nil
+}
+
+// dial opens an SSH connection to addr tunnelled through c, as ProxyJump does. The tunnel
+// has no deadlines, so a handshake stalled beyond cfg.Timeout is cut by closing it.
+func (c *SSHConn) dial(addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	conn, err := c.client.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("via %s: %w", c.label, err)
+	}
+	timer := time.AfterFunc(cfg.Timeout, func() { conn.Close() })
+	sc, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if !timer.Stop() && err == nil {
+		sc.Close()
+		err = errors.New("handshake timed out")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("via %s: %w", c.label, err)
+	}
+	return ssh.NewClient(sc, chans, reqs), nil
+}
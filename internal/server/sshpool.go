@@ -0,0 +1,222 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshProbeTimeout bounds the keepalive round trip of a pooled connection
+const sshProbeTimeout = 5 * time.Second
+
+// errScriptTimeout is returned by RunScript when the script outlives its timeout
+var errScriptTimeout = errors.New("script timed out")
+
+// errSSHPoolClosed is returned by connections requested after the pool was closed
+var errSSHPoolClosed = errors.New("SSH pool closed")
+
+// SSHPool keeps one SSH connection per target open across poll cycles, so a poll opens
+// sessions on an established connection instead of redoing the TCP and SSH handshakes.
+// Idle connections are kept alive with keepalive requests; dead ones are dropped and
+// redialed on the next poll. Sessions are limited per host, since sshd refuses sessions
+// beyond its MaxSessions.
+type SSHPool struct {
+	keepalive   time.Duration
+	maxSessions int
+
+	mu     sync.Mutex
+	conns  map[string]*SSHConn
+	slots  map[string]chan struct{} // session slots per host address
+	closed bool
+}
+
+// SSHConn is a pooled SSH connection
+type SSHConn struct {
+	client *ssh.Client
+	slots  chan struct{}
+	label  string // user@host:port, for logs; the pool key may hold a password
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewSSHPool creates a pool; keepalive 0 disables keepalives between polls and
+// maxSessions <= 0 leaves sessions unlimited
+func NewSSHPool(keepalive time.Duration, maxSessions int) *SSHPool {
+	return &SSHPool{
+		keepalive:   keepalive,
+		maxSessions: maxSessions,
+		conns:       map[string]*SSHConn{},
+		slots:       map[string]chan struct{}{},
+	}
+}
+
+// Get returns the pooled connection of key, checking that it still answers, or dials a
+// new one. addr is the "host:port" sessions are counted against.
+func (p *SSHPool) Get(key, user, addr string, dial func() (*ssh.Client, error)) (*SSHConn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, errSSHPoolClosed
+	}
+	c := p.conns[key]
+	p.mu.Unlock()
+	if c != nil {
+		err := c.ping()
+		if err == nil {
+			return c, nil
+		}
+		log.Printf("[SSH] connection to %s lost, reconnecting: %v", c.label, err)
+		p.drop(key, c)
+	}
+
+	client, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		client.Close()
+		return nil, errSSHPoolClosed
+	}
+	if c := p.conns[key]; c != nil {
+		// dialed concurrently for the same target; keep the first connection
+		client.Close()
+		return c, nil
+	}
+	c = &SSHConn{client: client, slots: p.hostSlots(addr), label: user + "@" + addr, done: make(chan struct{})}
+	p.conns[key] = c
+	go func() {
+		// the server closed the connection or the keepalive failed
+		client.Wait()
+		p.drop(key, c)
+	}()
+	if p.keepalive > 0 {
+		go p.keepAlive(key, c)
+	}
+	return c, nil
+}
+
+// hostSlots returns the session semaphore of a host; callers hold p.mu
+func (p *SSHPool) hostSlots(addr string) chan struct{} {
+	if p.maxSessions <= 0 {
+		return nil
+	}
+	s, ok := p.slots[addr]
+	if !ok {
+		s = make(chan struct{}, p.maxSessions)
+		p.slots[addr] = s
+	}
+	return s
+}
+
+// keepAlive pings an idle connection so that firewalls and NAT keep its state, and
+// closes it when the server stops answering
+func (p *SSHPool) keepAlive(key string, c *SSHConn) {
+	ticker := time.NewTicker(p.keepalive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.ping(); err != nil {
+				log.Printf("[SSH] keepalive to %s failed: %v", c.label, err)
+				p.drop(key, c)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// drop closes c and removes it from the pool unless it was already replaced
+func (p *SSHPool) drop(key string, c *SSHConn) {
+	p.mu.Lock()
+	if p.conns[key] == c {
+		delete(p.conns, key)
+	}
+	p.mu.Unlock()
+	c.close()
+}
+
+// isClosed reports whether Close was called
+func (p *SSHPool) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Close closes every pooled connection; later Get calls fail
+func (p *SSHPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	conns := p.conns
+	p.conns = map[string]*SSHConn{}
+	p.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// RunScript runs a POSIX sh script, fed on stdin so that it does not depend on the login
+// shell, in a new session and returns its combined output. It waits for a free session
+// slot of the host first. A script still running after timeout is cut by closing the
+// connection, which drops it from the pool, as a hung session means a hung server.
+func (c *SSHConn) RunScript(script string, timeout time.Duration) (string, error) {
+	if c.slots != nil {
+		select {
+		case c.slots <- struct{}{}:
+			defer func() { <-c.slots }()
+		case <-c.done:
+			return "", fmt.Errorf("connection to %s closed", c.label)
+		}
+	}
+	s, err := c.client.NewSession()
+	if err != nil {
+		var refused *ssh.OpenChannelError
+		if !errors.As(err, &refused) {
+			// not a refused session: the connection itself is gone
+			c.close()
+		}
+		return "", err
+	}
+	defer s.Close()
+	s.Stdin = strings.NewReader(script)
+	timer := time.AfterFunc(timeout, c.close)
+	out, err := s.CombinedOutput("sh -s")
+	if !timer.Stop() {
+		err = fmt.Errorf("%w after %s", errScriptTimeout, timeout)
+	}
+	return string(out), err
+}
+
+// ping sends a keepalive request; servers answer unknown requests with a failure
+// reply, which still proves the connection is alive
+func (c *SSHConn) ping() error {
+	res := make(chan error, 1)
+	go func() {
+		_, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil)
+		res <- err
+	}()
+	select {
+	case err := <-res:
+		return err
+	case <-time.After(sshProbeTimeout):
+		// closing the connection unblocks the request
+		return fmt.Errorf("no keepalive reply within %s", sshProbeTimeout)
+	case <-c.done:
+		return errors.New("connection closed")
+	}
+}
+
+func (c *SSHConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.client.Close()
+	})
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	m "monserv/internal/metrics"
+)
+
+// counterFiles are the cumulative counter files sampled twice to derive rates; /proc/uptime
+// timestamps each sample
+var counterFiles = []string{"/proc/uptime", "/proc/stat", "/proc/diskstats", "/proc/net/dev", "/proc/vmstat", procPidStatGlob}
+
+// pressureFiles ride along with the counter samples to save a round trip; only the
+// second sample is used, and kernels without PSI simply lack them
+var pressureFiles = []string{"/proc/pressure/cpu", "/proc/pressure/memory", "/proc/pressure/io"}
+
+// parsePressureSample builds the PSI section from a counter sample; nil when the kernel has no PSI
+func parsePressureSample(sample map[string]string) *m.Pressure {
+	p := m.Pressure{
+		CPU:    m.ParsePressure(sample["/proc/pressure/cpu"]),
+		Memory: m.ParsePressure(sample["/proc/pressure/memory"]),
+		IO:     m.ParsePressure(sample["/proc/pressure/io"]),
+	}
+	if p.CPU == nil && p.Memory == nil && p.IO == nil {
+		return nil
+	}
+	return &p
+}
+
+// procPidStatGlob is expanded by the remote shell to every process stat file
+const procPidStatGlob = "/proc/[0-9]*/stat"
+
+// counterSampleInterval is the sleep between the two counter samples
+const counterSampleInterval = time.Second
+
+// sampleGap is the time between two counter samples measured by their /proc/uptime. The
+// samples are further apart than the sleep on a loaded host, and rates over the sleep
+// alone would be overstated. Falls back to counterSampleInterval without a usable uptime.
+func sampleGap(prev, cur map[string]string) time.Duration {
+	uptime := func(sample map[string]string) (float64, bool) {
+		f := strings.Fields(sample["/proc/uptime"])
+		if len(f) == 0 {
+			return 0, false
+		}
+		v, err := strconv.ParseFloat(f[0], 64)
+		return v, err == nil
+	}
+	before, ok1 := uptime(prev)
+	after, ok2 := uptime(cur)
+	if !ok1 || !ok2 || after <= before {
+		return counterSampleInterval
+	}
+	return time.Duration((after - before) * float64(time.Second))
+}
+
+// sshScriptTimeout bounds a run of the collection script; a slow df on a hung NFS mount
+// must not stall the poll loop
+const sshScriptTimeout = 30 * time.Second
+
+const (
+	sampleSeparator = "--monserv-sample--"
+	fileMarker      = "--monserv-file "
+	statusMarker    = "--monserv-status "
+)
+
+// psCmd lists the owner and command line of every process. procps needs -e and a wide
+// user column; BusyBox rejects both, always lists every process and prints a header.
+const psCmd = "ps -eo pid=,user:32=,args= 2>/dev/null || ps -o pid,user,args"
+
+// scriptSection is a command of the collection script whose output is reported under name
+type scriptSection struct {
+	name string
+	cmd  string
+}
+
+// collectSections run once per poll, after the second counter sample. The commands stick
+// to POSIX sh and the options BusyBox supports.
+var collectSections = []scriptSection{
+	{"hostname", "cat /proc/sys/kernel/hostname"},
+	{"host", hostInfoCmd},
+	{"cpuinfo", "grep 'model name' /proc/cpuinfo | head -n 1"},
+	{"/proc/loadavg", "cat /proc/loadavg"},
+	{"/proc/meminfo", "cat /proc/meminfo"},
+	{"/proc/mounts", "cat /proc/mounts"},
+	{"df", "df -P -k"},
+	{"dfi", "df -P -i"},
+	{"sysfs", sysfsDumpCmd(sensorGlobs...)},
+	{"pagesize", "getconf PAGESIZE"},
+	{"ps", psCmd},
+	{"tcp", procNetTCPCmd},
+	{"sockets", socketOwnersCmd},
+}
+
+// collectScript is fed to "sh -s" on SSH targets, so it runs under a POSIX shell whatever
+// the login shell is. Numbers and error messages use the C locale; the character set is
+// left alone, as ps replaces characters it cannot print in command lines.
+var collectScript = buildCollectScript(slices.Concat(counterFiles, pressureFiles), collectSections)
+
+// buildCollectScript dumps the counter files twice, counterSampleInterval apart, then runs
+// every section. Each file and section is preceded by a fileMarker line, each section is
+// followed by a statusMarker line with its exit status, and the two counter samples are
+// split by a sampleSeparator line:
+//
+//	--monserv-file /proc/stat
+//	cpu  2255 34 2290 22625563 6290 127 456 0 0 0
+//	--monserv-sample--
+//	--monserv-file /proc/stat
+//	cpu  2256 34 2290 22625660 6290 127 456 0 0 0
+//	--monserv-file df
+//	Filesystem 1024-blocks Used Available Capacity Mounted on
+//	/dev/sda1 41152736 8930276 30109032 23% /
+//	--monserv-status 0
+func buildCollectScript(counters []string, sections []scriptSection) string {
+	var b strings.Builder
+	b.WriteString("unset LC_ALL; export LC_NUMERIC=C LC_MESSAGES=C\n")
+	var dump strings.Builder
+	for _, f := range counters {
+		fmt.Fprintf(&dump, "echo '%s%s'; cat %s 2>/dev/null\n", fileMarker, f, f)
+	}
+	b.WriteString(dump.String())
+	fmt.Fprintf(&b, "echo %s\nsleep %d\n", sampleSeparator, int(counterSampleInterval.Seconds()))
+	b.WriteString(dump.String())
+	for _, s := range sections {
+		fmt.Fprintf(&b, "echo '%s%s'; { %s; } 2>&1; echo \"%s$?\"\n", fileMarker, s.name, s.cmd, statusMarker)
+	}
+	return b.String()
+}
+
+// parseCollectOutput parses the output of the collection script into file and section
+// contents keyed by name, along with the exit status of every section that completed.
+// prev is nil when the second sample is missing; cur then holds the only sample.
+// err reports output that could not be read, such as a line over 1 MiB; what follows
+// it in the same sample is missing as in output cut short.
+func parseCollectOutput(out string) (prev, cur map[string]string, status map[string]int, err error) {
+	status = map[string]int{}
+	var samples []map[string]string
+	for _, chunk := range strings.Split(out, sampleSeparator+"\n") {
+		files := map[string]string{}
+		var name string
+		var body strings.Builder
+		flush := func() {
+			if name != "" {
+				files[name] = body.String()
+			}
+			name = ""
+			body.Reset()
+		}
+		sc := bufio.NewScanner(strings.NewReader(chunk))
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for sc.Scan() {
+			line := sc.Text()
+			if f, ok := strings.CutPrefix(line, fileMarker); ok {
+				flush()
+				name = f
+				continue
+			}
+			if v, ok := strings.CutPrefix(line, statusMarker); ok && name != "" {
+				if n, err := strconv.Atoi(v); err == nil {
+					status[name] = n
+				}
+				flush()
+				continue
+			}
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+		flush()
+		if scanErr := sc.Err(); scanErr != nil && err == nil {
+			err = fmt.Errorf("reading script output: %w", scanErr)
+		}
+		samples = append(samples, files)
+	}
+	if len(samples) < 2 {
+		return nil, samples[0], status, err
+	}
+	return samples[0], samples[1], status, err
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	m "monserv/internal/metrics"
+)
+
+// sensorGlobs are the sysfs files read for temperature sensors, following the
+// same layout gopsutil uses on the agent side
+var sensorGlobs = []string{
+	"/sys/class/hwmon/hwmon*/name",
+	"/sys/class/hwmon/hwmon*/temp*_input",
+	"/sys/class/hwmon/hwmon*/temp*_label",
+	"/sys/class/hwmon/hwmon*/temp*_max",
+	"/sys/class/hwmon/hwmon*/temp*_crit",
+	// CentOS keeps the inputs below an intermediate device directory
+	"/sys/class/hwmon/hwmon*/device/name",
+	"/sys/class/hwmon/hwmon*/device/temp*_input",
+	"/sys/class/hwmon/hwmon*/device/temp*_label",
+	"/sys/class/hwmon/hwmon*/device/temp*_max",
+	"/sys/class/hwmon/hwmon*/device/temp*_crit",
+	// legacy thermal zones, used when no hwmon input exists
+	"/sys/class/thermal/thermal_zone*/type",
+	"/sys/class/thermal/thermal_zone*/temp",
+}
+
+// sysfsDumpCmd prints every matching file as "path:content" lines.
+// Unmatched globs are passed literally to grep and silenced by -s.
+func sysfsDumpCmd(globs ...string) string {
+	return "grep -sH . " + strings.Join(globs, " ")
+}
+
+// parseSysfsDump turns "path:content" lines into file contents keyed by path
+func parseSysfsDump(out string) map[string]string {
+	files := map[string]string{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		p, v, ok := strings.Cut(sc.Text(), ":")
+		if !ok || !strings.HasPrefix(p, "/") {
+			continue
+		}
+		files[p] = strings.TrimSpace(v)
+	}
+	return files
+}
+
+// sensorsFromSysfs builds temperature sensors from sysfs file contents keyed by path.
+// hwmon temp*_input files are preferred; thermal zones are only used when none exist.
+// Values are millidegrees Celsius.
+func sensorsFromSysfs(files map[string]string) []m.Sensor {
+	milli := func(p string) float64 {
+		v, err := strconv.ParseFloat(files[p], 64)
+		if err != nil {
+			return 0
+		}
+		return v / 1000
+	}
+
+	var inputs, zones []string
+	for p := range files {
+		switch {
+		case strings.HasPrefix(p, "/sys/class/hwmon/") && strings.HasSuffix(p, "_input"):
+			inputs = append(inputs, p)
+		case strings.HasPrefix(p, "/sys/class/thermal/") && path.Base(p) == "temp":
+			zones = append(zones, path.Dir(p))
+		}
+	}
+	sort.Strings(inputs)
+	sort.Strings(zones)
+
+	sensors := []m.Sensor{}
+	for _, input := range inputs {
+		dir := path.Dir(input)
+		name, ok := files[path.Join(dir, "name")]
+		if !ok {
+			continue
+		}
+		if _, err := strconv.ParseFloat(files[input], 64); err != nil {
+			continue
+		}
+		base := path.Join(dir, strings.Split(path.Base(input), "_")[0])
+		// Format the label from "Core 0" to "core_0", like gopsutil does
+		if label := files[base+"_label"]; label != "" {
+			name += "_" + strings.Join(strings.Fields(strings.ToLower(label)), "_")
+		}
+		sensors = append(sensors, m.Sensor{
+			Name:         name,
+			TemperatureC: milli(input),
+			HighC:        milli(base + "_max"),
+			CriticalC:    milli(base + "_crit"),
+		})
+	}
+	if len(sensors) == 0 {
+		for _, zone := range zones {
+			name, ok := files[path.Join(zone, "type")]
+			if !ok {
+				continue
+			}
+			sensors = append(sensors, m.Sensor{Name: name, TemperatureC: milli(path.Join(zone, "temp"))})
+		}
+	}
+	return sensors
+}
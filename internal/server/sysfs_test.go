@@ -0,0 +1,168 @@
+package server
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	m "monserv/internal/metrics"
+)
+
+// dumpSysfs writes files, keyed by their /sys path, below a temporary root and reads
+// them back with sysfsDumpCmd as the SSH script would, the root stripped from the paths
+func dumpSysfs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep not available")
+	}
+	root := t.TempDir()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	globs := make([]string, len(sensorGlobs))
+	for i, g := range sensorGlobs {
+		globs[i] = root + g
+	}
+	// grep exits 2 for the globs that match nothing
+	out, _ := exec.Command("sh", "-c", sysfsDumpCmd(globs...)).Output()
+	return strings.ReplaceAll(string(out), root, "")
+}
+
+func TestSensorsFromSysfs(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []m.Sensor
+	}{
+		{
+			name: "hwmon with labels",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon0/name":        "coretemp",
+				"/sys/class/hwmon/hwmon0/temp1_input": "45000",
+				"/sys/class/hwmon/hwmon0/temp1_label": "Package id 0",
+				"/sys/class/hwmon/hwmon0/temp1_max":   "80000",
+				"/sys/class/hwmon/hwmon0/temp1_crit":  "100000",
+				"/sys/class/hwmon/hwmon0/temp2_input": "41500",
+				"/sys/class/hwmon/hwmon0/temp2_label": "Core 0",
+			},
+			want: []m.Sensor{
+				{Name: "coretemp_package_id_0", TemperatureC: 45, HighC: 80, CriticalC: 100},
+				{Name: "coretemp_core_0", TemperatureC: 41.5},
+			},
+		},
+		{
+			name: "hwmon without labels",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon1/name":        "nvme",
+				"/sys/class/hwmon/hwmon1/temp1_input": "38850",
+				"/sys/class/hwmon/hwmon1/temp1_crit":  "84850",
+			},
+			want: []m.Sensor{
+				{Name: "nvme", TemperatureC: 38.85, CriticalC: 84.85},
+			},
+		},
+		{
+			name: "inputs below device",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon0/device/name":        "k10temp",
+				"/sys/class/hwmon/hwmon0/device/temp1_input": "52125",
+				"/sys/class/hwmon/hwmon0/device/temp1_label": "Tctl",
+			},
+			want: []m.Sensor{
+				{Name: "k10temp_tctl", TemperatureC: 52.125},
+			},
+		},
+		{
+			name: "thermal zones without hwmon",
+			files: map[string]string{
+				"/sys/class/thermal/thermal_zone0/type": "cpu-thermal",
+				"/sys/class/thermal/thermal_zone0/temp": "51540",
+				"/sys/class/thermal/thermal_zone1/type": "gpu-thermal",
+				"/sys/class/thermal/thermal_zone1/temp": "49000",
+			},
+			want: []m.Sensor{
+				{Name: "cpu-thermal", TemperatureC: 51.54},
+				{Name: "gpu-thermal", TemperatureC: 49},
+			},
+		},
+		{
+			name: "thermal zones ignored next to hwmon",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon0/name":          "acpitz",
+				"/sys/class/hwmon/hwmon0/temp1_input":   "27800",
+				"/sys/class/thermal/thermal_zone0/type": "acpitz",
+				"/sys/class/thermal/thermal_zone0/temp": "27800",
+			},
+			want: []m.Sensor{
+				{Name: "acpitz", TemperatureC: 27.8},
+			},
+		},
+		{
+			name: "duplicate names",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon0/name":        "acpitz",
+				"/sys/class/hwmon/hwmon0/temp1_input": "27800",
+				"/sys/class/hwmon/hwmon0/temp2_input": "29800",
+				"/sys/class/hwmon/hwmon1/name":        "acpitz",
+				"/sys/class/hwmon/hwmon1/temp1_input": "31000",
+			},
+			// suffixed by the poller when the sample is recorded
+			want: []m.Sensor{
+				{Name: "acpitz", TemperatureC: 27.8},
+				{Name: "acpitz", TemperatureC: 29.8},
+				{Name: "acpitz", TemperatureC: 31},
+			},
+		},
+		{
+			name: "unreadable input and missing name",
+			files: map[string]string{
+				"/sys/class/hwmon/hwmon0/name":        "coretemp",
+				"/sys/class/hwmon/hwmon0/temp1_input": "",
+				"/sys/class/hwmon/hwmon1/temp1_input": "40000",
+			},
+			want: []m.Sensor{},
+		},
+		{
+			name:  "no sensors",
+			files: map[string]string{},
+			want:  []m.Sensor{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sensorsFromSysfs(parseSysfsDump(dumpSysfs(t, tt.files)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sensorsFromSysfs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSysfsDump(t *testing.T) {
+	out := "/sys/class/hwmon/hwmon0/name:coretemp\n" +
+		"/sys/class/hwmon/hwmon0/temp1_label:Package id 0\n" +
+		"/sys/class/thermal/thermal_zone0/type:x86_pkg_temp\n" +
+		"grep: /sys/class/hwmon/hwmon1/temp1_input: No data available\n" +
+		"/sys/class/hwmon/hwmon2/temp1_input:38\n" +
+		"/sys/class/hwmon/hwmon2/temp1_label:Composite: nvme0\n" +
+		"\n"
+	want := map[string]string{
+		"/sys/class/hwmon/hwmon0/name":          "coretemp",
+		"/sys/class/hwmon/hwmon0/temp1_label":   "Package id 0",
+		"/sys/class/thermal/thermal_zone0/type": "x86_pkg_temp",
+		"/sys/class/hwmon/hwmon2/temp1_input":   "38",
+		"/sys/class/hwmon/hwmon2/temp1_label":   "Composite: nvme0",
+	}
+	if got := parseSysfsDump(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSysfsDump() = %v, want %v", got, want)
+	}
+}
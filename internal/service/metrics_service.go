@@ -2,10 +2,13 @@ package service
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"monserv/internal/dto"
+	m "monserv/internal/metrics"
 	"monserv/internal/repository"
 	"monserv/internal/utils"
 )
@@ -16,8 +19,13 @@ type MetricsService interface {
 	GetServerMetrics(serverURL string) (*dto.ServerMetricsResponse, error)
 	GetActiveAlerts() []dto.AlertResponse
 	GetServerHealth() *dto.HealthResponse
+	GetInventory(filter dto.InventoryFilter) *dto.InventoryResponse
+	GetEvents(filter dto.EventFilter) *dto.EventListResponse
 }
 
+// defaultEventLimit is the number of events returned when the request sets no limit
+const defaultEventLimit = 100
+
 type metricsService struct {
 	repo          repository.MetricsRepository
 	memThreshold  float64
@@ -125,6 +133,15 @@ func (s *metricsService) GetActiveAlerts() []dto.AlertResponse {
 
 		// Determine severity and message based on type
 		switch alertType {
+		case "load":
+			alert.Severity = "warning"
+			alert.Subject = fmt.Sprintf("[ALERT] %s CPU load high", metrics.Hostname)
+			alert.Message = fmt.Sprintf("Load %.2f on %d cores = %.2f per core",
+				metrics.CPU.Load5, metrics.CPU.Cores, metrics.CPU.LoadPerCore())
+		case "iowait":
+			alert.Severity = "warning"
+			alert.Subject = fmt.Sprintf("[ALERT] %s CPU iowait high", metrics.Hostname)
+			alert.Message = fmt.Sprintf("CPU iowait %.1f%%", metrics.CPU.IOWaitPercent)
 		case "mem":
 			if metrics.Memory.UsedPercent >= s.memThreshold {
 				alert.Severity = "critical"
@@ -132,6 +149,11 @@ func (s *metricsService) GetActiveAlerts() []dto.AlertResponse {
 				alert.Message = fmt.Sprintf("Memory used %.1f%% (threshold %.1f%%)",
 					metrics.Memory.UsedPercent, s.memThreshold)
 			}
+		case "swap":
+			alert.Severity = "warning"
+			alert.Subject = fmt.Sprintf("[ALERT] %s swap high", metrics.Hostname)
+			alert.Message = fmt.Sprintf("Swap used %.1f%%, in %.0f B/s out %.0f B/s",
+				metrics.Memory.SwapUsedPercent, metrics.Memory.SwapInPerSec, metrics.Memory.SwapOutPerSec)
 		case "disk":
 			for _, disk := range metrics.Disks {
 				if disk.UsedPercent >= s.diskThreshold {
@@ -142,6 +164,190 @@ func (s *metricsService) GetActiveAlerts() []dto.AlertResponse {
 					break
 				}
 			}
+		case "inode":
+			if len(parts) >= 3 {
+				for _, disk := range metrics.Disks {
+					if disk.Mountpoint != parts[2] {
+						continue
+					}
+					alert.Severity = "critical"
+					alert.Subject = fmt.Sprintf("[ALERT] %s inodes %s high", metrics.Hostname, disk.Mountpoint)
+					alert.Message = fmt.Sprintf("Inodes on %s used %.1f%% (%d of %d)",
+						disk.Mountpoint, disk.InodesUsedPercent, disk.InodesUsed, disk.InodesTotal)
+					break
+				}
+			}
+		case "diskio":
+			if len(parts) >= 4 {
+				for _, d := range metrics.DiskIO {
+					if d.Device != parts[2] {
+						continue
+					}
+					alert.Severity = "warning"
+					if parts[3] == "util" {
+						alert.Subject = fmt.Sprintf("[ALERT] %s disk I/O %s utilisation high", metrics.Hostname, d.Device)
+						alert.Message = fmt.Sprintf("Device %s busy %.1f%%", d.Device, d.UtilPercent)
+					} else {
+						alert.Subject = fmt.Sprintf("[ALERT] %s disk I/O %s latency high", metrics.Hostname, d.Device)
+						alert.Message = fmt.Sprintf("Device %s await %.1fms", d.Device, d.AwaitMs)
+					}
+					break
+				}
+			}
+		case "net":
+			if len(parts) >= 4 {
+				for _, n := range metrics.Network {
+					if n.Name != parts[2] {
+						continue
+					}
+					alert.Severity = "warning"
+					if parts[3] == "errors" {
+						alert.Subject = fmt.Sprintf("[ALERT] %s net %s errors high", metrics.Hostname, n.Name)
+						alert.Message = fmt.Sprintf("Interface %s errors+drops %.1f/s",
+							n.Name, n.ErrorsPerSec+n.DropsPerSec)
+					} else {
+						alert.Subject = fmt.Sprintf("[ALERT] %s net %s throughput high", metrics.Hostname, n.Name)
+						alert.Message = fmt.Sprintf("Interface %s rx %.0f B/s tx %.0f B/s",
+							n.Name, n.BytesRecvPerSec, n.BytesSentPerSec)
+					}
+					break
+				}
+			}
+		case "temp":
+			if len(parts) >= 3 {
+				for _, sn := range metrics.Sensors {
+					if sn.Name != parts[2] {
+						continue
+					}
+					alert.Severity = "critical"
+					alert.Subject = fmt.Sprintf("[ALERT] %s temperature %s high", metrics.Hostname, sn.Name)
+					alert.Message = fmt.Sprintf("Sensor %s at %.1f°C", sn.Name, sn.TemperatureC)
+					break
+				}
+			}
+		case "container":
+			if len(parts) >= 4 {
+				name, kind := parts[2], parts[3]
+				alert.Severity = "warning"
+				if kind == "down" {
+					alert.Severity = "critical"
+					alert.Subject = fmt.Sprintf("[ALERT] %s container %s down", metrics.Hostname, name)
+					alert.Message = fmt.Sprintf("Container %s is missing", name)
+				}
+				for _, ct := range metrics.Containers {
+					if ct.Name != name {
+						continue
+					}
+					switch kind {
+					case "cpu":
+						alert.Subject = fmt.Sprintf("[ALERT] %s container %s CPU high", metrics.Hostname, name)
+						alert.Message = fmt.Sprintf("Container %s uses %.1f%% CPU", name, ct.CPUPercent)
+					case "mem":
+						alert.Subject = fmt.Sprintf("[ALERT] %s container %s memory high", metrics.Hostname, name)
+						alert.Message = fmt.Sprintf("Container %s uses %.1f%% of its memory limit", name, ct.MemoryPercent)
+					case "restart":
+						alert.Subject = fmt.Sprintf("[ALERT] %s container %s restarted", metrics.Hostname, name)
+						alert.Message = fmt.Sprintf("Container %s has restarted %d time(s)", name, ct.RestartCount)
+					case "down":
+						alert.Message = fmt.Sprintf("Container %s is %s", name, ct.State)
+					}
+					break
+				}
+			}
+		case "check":
+			if len(parts) >= 4 {
+				for _, ck := range metrics.Checks {
+					if ck.Name != parts[2] {
+						continue
+					}
+					alert.Severity = "warning"
+					if parts[3] == "critical" {
+						alert.Severity = "critical"
+					}
+					alert.Subject = fmt.Sprintf("[ALERT] %s check %s %s", metrics.Hostname, ck.Name, strings.ToUpper(parts[3]))
+					alert.Message = fmt.Sprintf("Check %s is %s: %s", ck.Name, ck.Status, ck.Output)
+					break
+				}
+			}
+		case "collector":
+			if len(parts) >= 3 {
+				alert.Severity = "warning"
+				alert.Subject = fmt.Sprintf("[ALERT] %s collector %s failing", metrics.Hostname, parts[2])
+				alert.Message = fmt.Sprintf("Collector %s failed: %s", parts[2], metrics.CollectionErrors[parts[2]])
+			}
+		case "tcp":
+			if len(parts) >= 4 {
+				alert.Severity = "warning"
+				switch parts[2] {
+				case "state":
+					alert.Subject = fmt.Sprintf("[ALERT] %s TCP %s high", metrics.Hostname, parts[3])
+					alert.Message = fmt.Sprintf("%d TCP sockets in %s", metrics.TCP.States[parts[3]], parts[3])
+				case "remote":
+					alert.Subject = fmt.Sprintf("[ALERT] %s TCP connections to %s high", metrics.Hostname, parts[3])
+					for _, r := range metrics.TCP.TopRemotes {
+						if r.Endpoint() == parts[3] {
+							alert.Message = fmt.Sprintf("%d connections to %s, %d in CLOSE_WAIT", r.Count, parts[3], r.CloseWait)
+							break
+						}
+					}
+				}
+			}
+		case "procwatch":
+			if len(parts) >= 4 {
+				for _, w := range metrics.ProcessWatches {
+					if w.Name != parts[2] {
+						continue
+					}
+					if parts[3] == "missing" {
+						alert.Severity = "critical"
+						alert.Subject = fmt.Sprintf("[ALERT] %s watched process %s missing", metrics.Hostname, w.Name)
+						alert.Message = fmt.Sprintf("Process watch %s matches %d process(es), at least %d required", w.Name, w.Count, w.MinCount)
+					} else {
+						alert.Severity = "warning"
+						alert.Subject = fmt.Sprintf("[ALERT] %s watched process %s restarted", metrics.Hostname, w.Name)
+						alert.Message = fmt.Sprintf("Process watch %s restarted, youngest process up %.0fs", w.Name, w.UptimeSeconds)
+					}
+					break
+				}
+			}
+		case "psi":
+			if len(parts) >= 4 {
+				// stalls of all tasks at once are critical, stalls of some tasks a warning
+				alert.Severity = "warning"
+				if parts[3] == "full" {
+					alert.Severity = "critical"
+				}
+				alert.Subject = fmt.Sprintf("[ALERT] %s %s pressure high", metrics.Hostname, parts[2])
+				if avg, ok := metrics.Pressure.Resource(parts[2]).Avg60(parts[3]); ok {
+					alert.Message = fmt.Sprintf("Tasks stalled on %s (%s) %.1f%% of the last 60s", parts[2], parts[3], avg)
+				}
+			}
+		case "log":
+			if len(parts) >= 3 {
+				for _, lw := range metrics.LogWatches {
+					if lw.Name != parts[2] {
+						continue
+					}
+					alert.Severity = "warning"
+					alert.Subject = fmt.Sprintf("[ALERT] %s log %s matches high", metrics.Hostname, lw.Name)
+					alert.Message = fmt.Sprintf("Log watch %s matched %d times in %ds (threshold %d)",
+						lw.Name, lw.Matches, lw.WindowSeconds, lw.Threshold)
+					break
+				}
+			}
+		case "unit":
+			if len(parts) >= 3 {
+				for _, u := range metrics.SystemdUnits {
+					if u.Name != parts[2] {
+						continue
+					}
+					alert.Severity = "critical"
+					alert.Subject = fmt.Sprintf("[ALERT] %s unit %s failed", metrics.Hostname, u.Name)
+					alert.Message = fmt.Sprintf("Unit %s is %s/%s (load %s, restarts %d)",
+						u.Name, u.ActiveState, u.SubState, u.LoadState, u.Restarts)
+					break
+				}
+			}
 		case "proc":
 			if len(parts) >= 3 {
 				// Process-specific alert
@@ -155,6 +361,19 @@ func (s *metricsService) GetActiveAlerts() []dto.AlertResponse {
 					}
 				}
 			}
+		case "proccpu":
+			if len(parts) >= 3 {
+				for _, proc := range metrics.TopProcsByCPU {
+					if strconv.Itoa(int(proc.PID)) != parts[2] {
+						continue
+					}
+					alert.Severity = "warning"
+					alert.Subject = fmt.Sprintf("[ALERT] %s proc %s CPU high", metrics.Hostname, proc.Name)
+					alert.Message = fmt.Sprintf("Process %s (PID %d) uses %.1f%% CPU",
+						proc.Name, proc.PID, proc.CPUPercent)
+					break
+				}
+			}
 		}
 
 		// Mask password in URL before sending to API
@@ -192,7 +411,7 @@ func (s *metricsService) GetServerHealth() *dto.HealthResponse {
 			Status:   status,
 		}
 
-		if status == "online" || status == "warning" || status == "alert" {
+		if status == "online" || status == "warning" || status == "alert" || status == "degraded_data" {
 			online++
 		} else {
 			offline++
@@ -224,7 +443,99 @@ func (s *metricsService) GetServerHealth() *dto.HealthResponse {
 	}
 }
 
-func (s *metricsService) determineStatus(url string, metrics interface{}) string {
+func (s *metricsService) GetInventory(filter dto.InventoryFilter) *dto.InventoryResponse {
+	all := s.repo.GetAll()
+
+	hosts := make([]dto.InventoryHostResponse, 0, len(all))
+	for url, metrics := range all {
+		if metrics == nil {
+			continue
+		}
+		h := metrics.Host
+		if !matchInventory(metrics.Hostname, filter.Hostname) ||
+			!matchInventory(h.OS, filter.OS) ||
+			!matchInventory(h.Platform, filter.Platform) ||
+			!matchInventory(h.PlatformFamily, filter.PlatformFamily) ||
+			!matchInventory(h.PlatformVersion, filter.PlatformVersion) ||
+			!matchInventory(h.KernelVersion, filter.KernelVersion) ||
+			!matchInventory(h.Arch, filter.Arch) ||
+			!matchInventory(h.VirtualizationSystem, filter.Virtualization) ||
+			!matchInventory(h.VirtualizationRole, filter.Role) ||
+			!matchInventory(h.AgentVersion, filter.AgentVersion) {
+			continue
+		}
+		lastUpdate := s.repo.GetLastUpdate(url)
+		if lastUpdate.IsZero() {
+			lastUpdate = metrics.GeneratedAtUTC
+		}
+		hosts = append(hosts, dto.InventoryHostResponse{
+			URL:           utils.MaskPassword(url),
+			Hostname:      metrics.Hostname,
+			Status:        s.determineStatus(url, metrics),
+			UptimeSeconds: metrics.UptimeSeconds,
+			Host:          dto.ToHostInfoResponse(h),
+			LastUpdate:    lastUpdate,
+		})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if hosts[i].Hostname != hosts[j].Hostname {
+			return hosts[i].Hostname < hosts[j].Hostname
+		}
+		return hosts[i].URL < hosts[j].URL
+	})
+
+	return &dto.InventoryResponse{
+		Hosts: hosts,
+		Total: len(hosts),
+	}
+}
+
+func (s *metricsService) GetEvents(filter dto.EventFilter) *dto.EventListResponse {
+	events := s.repo.GetEvents()
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
+
+	result := make([]dto.EventResponse, 0, min(limit, len(events)))
+	for i := len(events) - 1; i >= 0 && len(result) < limit; i-- {
+		e := events[i]
+		maskedURL := utils.MaskPassword(e.ServerURL)
+		if filter.URL != "" && filter.URL != e.ServerURL && filter.URL != maskedURL {
+			continue
+		}
+		if !matchInventory(e.Type, filter.Type) || !matchInventory(e.Hostname, filter.Hostname) {
+			continue
+		}
+		result = append(result, dto.EventResponse{
+			ServerURL:  maskedURL,
+			Hostname:   e.Hostname,
+			Type:       e.Type,
+			Subject:    e.Subject,
+			Message:    e.Message,
+			OccurredAt: e.OccurredAt,
+		})
+	}
+
+	return &dto.EventListResponse{
+		Events: result,
+		Total:  len(result),
+	}
+}
+
+// matchInventory compares an inventory attribute with a filter value: empty matches
+// everything, the comparison ignores case and a trailing "*" matches a prefix
+func matchInventory(value, want string) bool {
+	if want == "" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(want, "*"); ok {
+		return len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix)
+	}
+	return strings.EqualFold(value, want)
+}
+
+func (s *metricsService) determineStatus(url string, metrics *m.ServerMetrics) string {
 	if metrics == nil {
 		return "offline"
 	}
@@ -241,11 +552,19 @@ func (s *metricsService) determineStatus(url string, metrics interface{}) string
 		if strings.HasPrefix(key, url+"|") {
 			parts := strings.Split(key, "|")
 			if len(parts) >= 2 {
-				alertType := parts[1]
-				if alertType == "mem" || alertType == "disk" {
+				switch parts[1] {
+				case "mem", "disk", "inode", "temp", "unit":
 					hasAlert = true
-				} else if alertType == "proc" {
+				case "proc", "proccpu", "load", "iowait", "swap", "diskio", "net", "log", "tcp", "collector":
 					hasWarning = true
+				case "container", "check", "procwatch", "psi":
+					// a must-run container or process being down, a CRITICAL check or all tasks stalling
+					// on a resource is as severe as a host alert
+					if len(parts) >= 4 && (parts[3] == "down" || parts[3] == "critical" || parts[3] == "missing" || parts[3] == "full") {
+						hasAlert = true
+					} else {
+						hasWarning = true
+					}
 				}
 			}
 		}
@@ -257,5 +576,9 @@ func (s *metricsService) determineStatus(url string, metrics interface{}) string
 	if hasWarning {
 		return "warning"
 	}
+	// Some sub-collectors failed, so parts of the data may be missing or zero
+	if len(metrics.CollectionErrors) > 0 {
+		return "degraded_data"
+	}
 	return "online"
 }
@@ -94,6 +94,24 @@ func (h *Hub) BroadcastAlert(alertType, subject, message string) {
 	h.broadcast <- data
 }
 
+// BroadcastEvent sends a one-shot host event, such as an OOM kill or a reboot, to all connected clients
+func (h *Hub) BroadcastEvent(e m.Event) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type":        "event",
+		"event_type":  e.Type,
+		"hostname":    e.Hostname,
+		"subject":     e.Subject,
+		"message":     e.Message,
+		"occurred_at": e.OccurredAt,
+	})
+	if err != nil {
+		log.Printf("[WebSocket] Error marshaling event: %v", err)
+		return
+	}
+
+	h.broadcast <- data
+}
+
 // GetClientCount returns the number of connected clients
 func (h *Hub) GetClientCount() int {
 	h.mu.RLock()
@@ -59,6 +59,9 @@ function connectWebSocket() {
         } else if (data.type === 'alert') {
           // Show alert notification
           handleAlert(data);
+        } else if (data.type === 'event') {
+          // One-shot host event (OOM kill, reboot)
+          handleEvent(data);
         }
       } catch (e) {
         console.error('Error parsing WebSocket message:', e);
@@ -158,6 +161,13 @@ function handleAlert(alert) {
   }
 }
 
+// Handle one-shot host events; they have no recovery
+function handleEvent(event) {
+  showNotification(event.subject, event.message, 'warning');
+  console.log(`[EVENT ${event.event_type}]`, event.subject, '-', event.message);
+  playAlertSound();
+}
+
 // Show notification
 function showNotification(title, message, type = 'info') {
   // Try to use browser notifications
@@ -111,11 +111,35 @@ export function updateAgentCardData(card, m, url, chartId) {
   const disks = m.disks || [];
   const procs = m.topProcsByMem || [];
   card.querySelector('.agent-hostname').textContent = m.hostname || url;
-  card.querySelector('.agent-meta').innerHTML = `Agent: ${maskedUrl} • Uptime: ${m.uptimeSeconds||0}s • At: ${m.generatedAtUtc||''}`;
-  card.querySelector('.agent-cpu').innerHTML = `Cores: ${cpu.cores||'-'} • Model: ${cpu.modelName||'-'} • Usage: ${cpu.usedPercent?badge(cpu.usedPercent, window.CPU_TH || 80):'-'}`;
+  card.querySelector('.agent-meta').innerHTML = `Agent: ${maskedUrl} • Uptime: ${m.uptimeSeconds||0}s${hostNote(m.host)} • At: ${m.generatedAtUtc||''}${degradedNote(m.collectionErrors)}`;
+  card.querySelector('.agent-cpu').innerHTML = `Cores: ${cpu.cores||'-'} • Model: ${cpu.modelName||'-'} • Usage: ${cpu.usedPercent?badge(cpu.usedPercent, window.CPU_TH || 80):'-'}${pressureNote(m.pressure)}`;
   card.querySelector('.agent-memory').innerHTML = `Total: ${fmtBytes(mem.total||0)} • Used: ${fmtBytes(mem.used||0)} • Used: ${mem.usedPercent?badge(mem.usedPercent, window.MEM_TH || 90):'-'}`;
   const diskTbody = card.querySelector('.agent-disks tbody');
   diskTbody.innerHTML = disks.map(d=>`<tr><td>${d.mountpoint}</td><td>${d.fstype}</td><td>${fmtBytes(d.total)}</td><td>${fmtBytes(d.used)}</td><td>${fmtBytes(d.free)}</td><td>${badge(d.usedPercent, window.DISK_TH || 90)}</td></tr>`).join('');
   const procTbody = card.querySelector('.agent-procs tbody');
   procTbody.innerHTML = procs.map(p=>`<tr><td>${p.pid}</td><td>${p.name}</td><td>${p.username}</td><td>${fmtBytes(p.rssBytes)}</td><td>${badge(p.percentRAM||0, window.PROC_TH || 20)}</td></tr>`).join('');
 }
+
+// Platform, kernel and architecture from the host inventory, e.g. "ubuntu 22.04 • 5.15.0-91-generic x86_64"
+function hostNote(h) {
+  if (!h || !h.platform) return '';
+  const esc = (t) => String(t).replace(/&/g, '&amp;').replace(/</g, '&lt;');
+  const virt = h.virtualizationSystem ? ` (${h.virtualizationSystem})` : '';
+  return ` • ${esc(`${h.platform} ${h.platformVersion||''}`.trim())} • ${esc(`${h.kernelVersion||''} ${h.arch||''}`.trim())}${esc(virt)}`;
+}
+
+// 60 second PSI "some" averages; absent on kernels without pressure stall information
+function pressureNote(p) {
+  if (!p) return '';
+  const parts = ['cpu', 'memory', 'io'].filter(r => p[r]).map(r => `${r} ${p[r].some.avg60.toFixed(1)}%`);
+  return parts.length ? ` • Pressure: ${parts.join(' / ')}` : '';
+}
+
+// Failed sub-collectors mean parts of the card may show zeros instead of real values
+function degradedNote(errors) {
+  const names = Object.keys(errors || {}).sort();
+  if (names.length === 0) return '';
+  const esc = (t) => String(t).replace(/&/g, '&amp;').replace(/"/g, '&quot;').replace(/</g, '&lt;');
+  const title = names.map(n => `${n}: ${errors[n]}`).join('\n');
+  return ` • <span class="warn" title="${esc(title)}">degraded data: ${esc(names.join(', '))}</span>`;
+}